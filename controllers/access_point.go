@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"user-storage/models"
+	"user-storage/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,7 +13,11 @@ type AccessPointController struct {}
 
 func (t AccessPointController) GetAllAccessPoints(c *gin.Context) {
 	var accessPoints []models.AccessPoint
-	err := models.DB.Find(&accessPoints)
+	stamps, ok := stampFilter(c, services.StampSortKeys)
+	if !ok {
+		return
+	}
+	err := stamps.Apply(models.DB).Find(&accessPoints)
 	if err.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.HTTPError{
 			Code: http.StatusInternalServerError,
@@ -47,6 +52,41 @@ func (t AccessPointController) GetAccessPointByID(c *gin.Context) {
 	c.JSON(http.StatusOK, accessPoints)
 }
 
+//  @Summary        Match Access Points
+//  @Description    Retrieves every access point whose method and path pattern apply to a request with the given method and concrete path
+//  @Tags           access-points
+//  @Produce        json
+//  @Param          method  query   string  false   "HTTP method of the request"    default(GET)
+//  @Param          path    query   string  true    "Concrete request path, such as /users/accounts/42"
+//  @Success        200     {array}     models.AccessPoint
+//  @Failure        400     {object}    models.HTTPError    "Path cannot be empty"
+//  @Failure        403     {object}    models.HTTPError    "Caller is not an administrator"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /access-points/match   [get]
+func (t AccessPointController) MatchAccessPoints(c *gin.Context) {
+	method := c.DefaultQuery("method", "GET")
+	path := c.Query("path")
+
+	if path == "" {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code: http.StatusBadRequest,
+			Message: "Path cannot be empty",
+		})
+		return
+	}
+
+	accessPoints, code, err := services.NewAccessService(models.DB).MatchAccessPoints(method, path)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code: code,
+			Message: fmt.Sprintf("Error getting data. %v", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, *accessPoints)
+}
+
 func (t AccessPointController) AddAccessPoint(c *gin.Context) {
 	var accessPoint models.AccessPoint
 	if err := c.BindJSON(&accessPoint); err != nil {
@@ -56,6 +96,7 @@ func (t AccessPointController) AddAccessPoint(c *gin.Context) {
 		})
 		return
 	}
+	accessPoint.Stamps = models.Stamps{}
 
 	if err := validate.Struct(accessPoint); err != nil {
 		c.JSON(http.StatusBadRequest, models.HTTPError{
@@ -65,7 +106,22 @@ func (t AccessPointController) AddAccessPoint(c *gin.Context) {
 		return
 	}
 
-	if result := models.DB.Create(&accessPoint); result.Error != nil {
+	if err := services.NormalizeAccessPoint(&accessPoint); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code: http.StatusBadRequest,
+			Message: fmt.Sprintf("Unable to validate accessPoint. %v", err.Error()),
+		})
+		return
+	}
+
+	if accessPoint.Admin {
+		if code, err := services.NewPrivilegeService(models.DB).CheckSuperAdmin(callerId(c)); err != nil {
+			c.JSON(code, privilegeError(code, "Unable to create accessPoint.", err))
+			return
+		}
+	}
+
+	if result := models.DB.WithContext(c.Request.Context()).Create(&accessPoint); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.HTTPError{
 			Code: http.StatusInternalServerError,
 			Message: fmt.Sprintf("Unable to create accessPoint. %v", result.Error.Error()),
@@ -73,6 +129,7 @@ func (t AccessPointController) AddAccessPoint(c *gin.Context) {
 		return
 	}
 
+	c.Set("accessPoint", accessPoint)
 	c.JSON(http.StatusOK, accessPoint)
 }
 
@@ -95,6 +152,7 @@ func (t AccessPointController) UpdateAccessPoint(c *gin.Context) {
 		})
 		return
 	}
+	accessPoint.Stamps = models.Stamps{}
 
 	if err := validate.Struct(accessPoint); err != nil {
 		c.JSON(http.StatusBadRequest, models.HTTPError{
@@ -104,6 +162,14 @@ func (t AccessPointController) UpdateAccessPoint(c *gin.Context) {
 		return
 	}
 
+	if err := services.NormalizeAccessPoint(&accessPoint); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code: http.StatusBadRequest,
+			Message: fmt.Sprintf("Unable to validate accessPoint. %v", err.Error()),
+		})
+		return
+	}
+
 	// Check if access point exists
 	existingAP := models.AccessPoint{}
 	if err := models.DB.Where("id = ?", id).First(&existingAP).Error; err != nil {
@@ -113,8 +179,16 @@ func (t AccessPointController) UpdateAccessPoint(c *gin.Context) {
         })
         return
     }
+	c.Set("accessPoint", existingAP)
 
-	if result := models.DB.Model(&existingAP).Updates(&accessPoint); result.Error != nil {
+	if existingAP.Admin || accessPoint.Admin {
+		if code, err := services.NewPrivilegeService(models.DB).CheckSuperAdmin(callerId(c)); err != nil {
+			c.JSON(code, privilegeError(code, "Unable to update accessPoint.", err))
+			return
+		}
+	}
+
+	if result := models.DB.WithContext(c.Request.Context()).Model(&existingAP).Select("Name", "Method", "EndPoint", "Admin").Updates(&accessPoint); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.HTTPError{
 			Code: http.StatusInternalServerError,
 			Message: fmt.Sprintf("Unable to update accessPoint. %v", result.Error.Error()),
@@ -122,6 +196,7 @@ func (t AccessPointController) UpdateAccessPoint(c *gin.Context) {
 		return
 	}
 
+	c.Set("updatedAccessPoint", accessPoint)
 	c.JSON(http.StatusOK, existingAP)
 }
 
@@ -145,6 +220,13 @@ func (t AccessPointController) DeleteAccessPoint(c *gin.Context) {
 		return
 	}
 
+	if accessPoint.Admin {
+		if code, err := services.NewPrivilegeService(models.DB).CheckSuperAdmin(callerId(c)); err != nil {
+			c.JSON(code, privilegeError(code, "Unable to delete accessPoint.", err))
+			return
+		}
+	}
+
 	if result := models.DB.Delete(&accessPoint); result.Error != nil {
 		c.JSON(http.StatusNotFound, models.HTTPError{
 			Code: http.StatusNotFound,
@@ -153,6 +235,7 @@ func (t AccessPointController) DeleteAccessPoint(c *gin.Context) {
 		return
 	}
 
+	c.Set("accessPoint", accessPoint)
 	c.JSON(http.StatusOK, "Success")
 }
 
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"user-storage/middlewares"
+	"user-storage/models"
+	"user-storage/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AuthorizationController struct {
+	AccessService *services.AccessService
+}
+
+func NewAuthorizationController(db *gorm.DB) *AuthorizationController {
+	return &AuthorizationController{
+		AccessService: services.NewAccessService(db),
+	}
+}
+
+//  @Summary        Decide whether a subject may call endpoints
+//  @Description    Evaluate one or more method and path checks for a user id or raw ID token against role_access and the loaded policies. Unknown users are denied. Only admins may ask about a subject other than themselves
+//  @Tags           authorization
+//  @Produce        json
+//  @Param          request    body        models.AuthorizationRequest     true    "Subject and checks"
+//  @Success        200     {object}    models.AuthorizationResponse
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body"
+//  @Failure        401     {object}    models.HTTPError    "Subject token is invalid"
+//  @Failure        403     {object}    models.HTTPError    "Subject is someone else and the caller is not an admin"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /authorize   [post]
+func (t AuthorizationController) Authorize(c *gin.Context) {
+	var request models.AuthorizationRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid JSON request: %v", err.Error()),
+		})
+		return
+	}
+	if err := validate.Struct(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	subject := models.PolicySubject{UserId: request.Subject.UserId}
+	if request.Subject.Token != "" {
+		claims, code, err := middlewares.ParseToken(c.Request.Context(), request.Subject.Token)
+		if err != nil {
+			httpErr := models.HTTPError{
+				Code:    code,
+				Message: fmt.Sprintf("Invalid subject token. %v", err.Error()),
+			}
+			if claimErr, ok := err.(*middlewares.ClaimError); ok {
+				httpErr.Reason = claimErr.Reason
+			}
+			c.JSON(code, httpErr)
+			return
+		}
+		subject = models.PolicySubject{UserId: claims.UserId, Email: claims.Email, Role: claims.Role}
+	}
+	if subject.UserId == "" {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: "Subject must have a userId or token",
+		})
+		return
+	}
+
+	// Otherwise anyone could map out everyone else's permissions
+	if subject.UserId != callerId(c) {
+		isAdmin, code, err := t.AccessService.IsAdmin(callerId(c))
+		if err != nil {
+			c.JSON(code, models.HTTPError{
+				Code:    code,
+				Message: fmt.Sprintf("Unable to check permissions. %v", err.Error()),
+			})
+			return
+		}
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, models.HTTPError{
+				Code:    http.StatusForbidden,
+				Message: "Only administrators can authorize other subjects",
+				Reason:  "admin_required",
+			})
+			return
+		}
+	}
+
+	res, code, err := t.AccessService.Decide(subject, request.Checks)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to authorize. %v", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, *res)
+}
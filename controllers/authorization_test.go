@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func performAuthorize(authorization *AuthorizationController, callerId, body string) *httptest.ResponseRecorder {
+    gin.SetMode(gin.TestMode)
+    router := gin.New()
+    router.POST("/authorize", func(c *gin.Context) {
+        c.Set("userDetails", map[string]interface{}{"user_id": callerId})
+    }, authorization.Authorize)
+
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(body)))
+    return w
+}
+
+const isAdminQuery = "SELECT count(*) FROM `user_roles` JOIN roles ON roles.id = user_roles.role_id WHERE (user_roles.user_id = ? AND roles.name IN (?))"
+
+func TestAuthorize_OtherSubjectNeedsAdmin(t *testing.T) {
+    db, mock := newMockDB(t)
+    authorization := NewAuthorizationController(db)
+
+    mock.ExpectQuery(regexp.QuoteMeta(isAdminQuery)).
+        WithArgs("1", "Admin", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+    w := performAuthorize(authorization, "1", `{"subject":{"userId":"2"},"checks":[{"method":"GET","path":"/users/accounts"}]}`)
+
+    assert.Equal(t, http.StatusForbidden, w.Code)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorize_UnknownSubjectIsDenied(t *testing.T) {
+    db, mock := newMockDB(t)
+    authorization := NewAuthorizationController(db)
+
+    mock.ExpectQuery(regexp.QuoteMeta(isAdminQuery)).
+        WithArgs("1", "Admin", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+    mock.ExpectQuery(regexp.QuoteMeta(userExistsQuery)).
+        WithArgs("404").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+    w := performAuthorize(authorization, "1", `{"subject":{"userId":"404"},"checks":[{"method":"GET","path":"/users/accounts"}]}`)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.JSONEq(t, `{"userId":"404","decisions":[{"method":"GET","path":"/users/accounts","allowed":false,"accessPoint":null,"role":null,"reason":"User is not found"}]}`, w.Body.String())
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
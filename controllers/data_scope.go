@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"user-storage/models"
+	"user-storage/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DataScopeController struct {
+	DataScopeService *services.DataScopeService
+}
+
+func NewDataScopeController(db *gorm.DB) *DataScopeController {
+	return &DataScopeController{
+		DataScopeService: services.NewDataScopeService(db),
+	}
+}
+
+//  @Summary        Get all Data Scopes
+//  @Description    Retrieves the rules limiting which users each role can see
+//  @Tags           data-scopes
+//  @Produce        json
+//  @Success        200     {array}     models.DataScope
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /data-scopes  [get]
+func (t DataScopeController) GetAllDataScopes(c *gin.Context) {
+	res, code, err := t.DataScopeService.GetAllDataScopes()
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Error getting data. %v", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, *res)
+}
+
+//  @Summary        Add a Data Scope
+//  @Description    Let holders of roleId see users holding targetRoleId, or users without a role when targetRoleId is 0. Once a role has a data scope its holders only see users matching one of its scopes
+//  @Tags           data-scopes
+//  @Produce        json
+//  @Param          data-scope    body        models.DataScope     true    "Data Scope Details"
+//  @Success        201     {object}    models.DataScope
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body or unknown role"
+//  @Failure        409     {object}    models.HTTPError    "Data scope already exists"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /data-scopes [post]
+func (t DataScopeController) AddDataScope(c *gin.Context) {
+	var scope models.DataScope
+	if err := c.BindJSON(&scope); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid JSON request: %v", err.Error()),
+		})
+		return
+	}
+
+	res, code, err := t.DataScopeService.AddDataScope(&scope)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to create data scope. %v", err.Error()),
+		})
+		return
+	}
+
+	c.Set("dataScope", *res)
+	c.JSON(code, *res)
+}
+
+//  @Summary        Delete a Data Scope
+//  @Description    Delete a Data Scope
+//  @Tags           data-scopes
+//  @Produce        json
+//  @Param          data-scope    body        models.DataScope     true    "Data Scope Details"
+//  @Success        200     {object}    models.DataScope
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body"
+//  @Failure        404     {object}    models.HTTPError    "Data scope is not found"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /data-scopes [delete]
+func (t DataScopeController) DeleteDataScope(c *gin.Context) {
+	var scope models.DataScope
+	if err := c.BindJSON(&scope); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid JSON request: %v", err.Error()),
+		})
+		return
+	}
+
+	res, code, err := t.DataScopeService.DeleteDataScope(&scope)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to delete data scope. %v", err.Error()),
+		})
+		return
+	}
+
+	c.Set("dataScope", *res)
+	c.JSON(http.StatusOK, *res)
+}
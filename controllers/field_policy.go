@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"user-storage/models"
+	"user-storage/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type FieldPolicyController struct {
+	FieldPolicyService *services.FieldPolicyService
+}
+
+func NewFieldPolicyController(db *gorm.DB) *FieldPolicyController {
+	return &FieldPolicyController{
+		FieldPolicyService: services.NewFieldPolicyService(db),
+	}
+}
+
+//  @Summary        Get all Field Policies
+//  @Description    Retrieves the user fields each role may write
+//  @Tags           field-policies
+//  @Produce        json
+//  @Success        200     {array}     models.FieldPolicy
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /field-policies  [get]
+func (t FieldPolicyController) GetAllFieldPolicies(c *gin.Context) {
+	res, code, err := t.FieldPolicyService.GetAllFieldPolicies()
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Error getting data. %v", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, *res)
+}
+
+//  @Summary        Add a Field Policy
+//  @Description    Let holders of roleId write field (firstName, lastName, email or role) of users. Once a role has a field policy its holders may only write the fields it lists
+//  @Tags           field-policies
+//  @Produce        json
+//  @Param          field-policy    body        models.FieldPolicy     true    "Field Policy Details"
+//  @Success        201     {object}    models.FieldPolicy
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body, unknown field or unknown role"
+//  @Failure        409     {object}    models.HTTPError    "Field policy already exists"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /field-policies [post]
+func (t FieldPolicyController) AddFieldPolicy(c *gin.Context) {
+	var policy models.FieldPolicy
+	if err := c.BindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid JSON request: %v", err.Error()),
+		})
+		return
+	}
+
+	res, code, err := t.FieldPolicyService.AddFieldPolicy(&policy)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to create field policy. %v", err.Error()),
+		})
+		return
+	}
+
+	c.Set("fieldPolicy", *res)
+	c.JSON(code, *res)
+}
+
+//  @Summary        Delete a Field Policy
+//  @Description    Delete a Field Policy
+//  @Tags           field-policies
+//  @Produce        json
+//  @Param          field-policy    body        models.FieldPolicy     true    "Field Policy Details"
+//  @Success        200     {object}    models.FieldPolicy
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body"
+//  @Failure        404     {object}    models.HTTPError    "Field policy is not found"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /field-policies [delete]
+func (t FieldPolicyController) DeleteFieldPolicy(c *gin.Context) {
+	var policy models.FieldPolicy
+	if err := c.BindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid JSON request: %v", err.Error()),
+		})
+		return
+	}
+
+	res, code, err := t.FieldPolicyService.DeleteFieldPolicy(&policy)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to delete field policy. %v", err.Error()),
+		})
+		return
+	}
+
+	c.Set("fieldPolicy", *res)
+	c.JSON(http.StatusOK, *res)
+}
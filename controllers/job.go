@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"user-storage/models"
+	"user-storage/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type JobController struct {
+	DB *gorm.DB
+}
+
+func NewJobController(db *gorm.DB) *JobController {
+	return &JobController{DB: db}
+}
+
+//  @Summary        Run a maintenance job
+//  @Description    Run a maintenance job once, for deployments that schedule jobs with cron or similar rather than EventBridge. sweep-role-assignments removes expired role assignments, and purge-deleted-users permanently deletes users soft-deleted more than USER_RETENTION_DAYS ago, and reload-policies reloads POLICY_DIR if it changed
+//  @Tags           jobs
+//  @Produce        json
+//  @Param          name    path    string  true    "Job name"  Enums(sweep-role-assignments, purge-deleted-users, reload-policies)
+//  @Success        200     {object}    models.JobResult
+//  @Failure        403     {object}    models.HTTPError    "Caller is not an administrator"
+//  @Failure        404     {object}    models.HTTPError    "Job not found"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /jobs/{name}   [post]
+func (t JobController) RunJob(c *gin.Context) {
+	res, code, err := services.RunJob(c.Request.Context(), t.DB, c.Param("name"), time.Now())
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to run job. %v", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, *res)
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"user-storage/models"
+	"user-storage/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PIIPolicyController struct {
+	PIIService *services.PIIService
+}
+
+func NewPIIPolicyController(db *gorm.DB) *PIIPolicyController {
+	return &PIIPolicyController{
+		PIIService: services.NewPIIService(db),
+	}
+}
+
+//  @Summary        Get all PII Policies
+//  @Description    Retrieves how each role sees the personal fields of users
+//  @Tags           pii-policies
+//  @Produce        json
+//  @Success        200     {array}     models.PIIPolicy
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /pii-policies  [get]
+func (t PIIPolicyController) GetAllPIIPolicies(c *gin.Context) {
+	res, code, err := t.PIIService.GetAllPIIPolicies()
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Error getting data. %v", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, *res)
+}
+
+//  @Summary        Add a PII Policy
+//  @Description    Mask or omit field (firstName, lastName or email) of the users returned to holders of roleId. A field is shown in full if any role the caller holds has no policy for it. Adding a policy for an existing role and field changes its action
+//  @Tags           pii-policies
+//  @Produce        json
+//  @Param          pii-policy    body        models.PIIPolicy     true    "PII Policy Details"
+//  @Success        201     {object}    models.PIIPolicy
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body, unknown field or action, or unknown role"
+//  @Failure        409     {object}    models.HTTPError    "PII policy already exists"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /pii-policies [post]
+func (t PIIPolicyController) AddPIIPolicy(c *gin.Context) {
+	var policy models.PIIPolicy
+	if err := c.BindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid JSON request: %v", err.Error()),
+		})
+		return
+	}
+
+	res, code, err := t.PIIService.AddPIIPolicy(&policy)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to create PII policy. %v", err.Error()),
+		})
+		return
+	}
+
+	c.Set("piiPolicy", *res)
+	c.JSON(code, *res)
+}
+
+//  @Summary        Delete a PII Policy
+//  @Description    Delete a PII Policy
+//  @Tags           pii-policies
+//  @Produce        json
+//  @Param          pii-policy    body        models.PIIPolicy     true    "PII Policy Details"
+//  @Success        200     {object}    models.PIIPolicy
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body"
+//  @Failure        404     {object}    models.HTTPError    "PII policy is not found"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /pii-policies [delete]
+func (t PIIPolicyController) DeletePIIPolicy(c *gin.Context) {
+	var policy models.PIIPolicy
+	if err := c.BindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid JSON request: %v", err.Error()),
+		})
+		return
+	}
+
+	res, code, err := t.PIIService.DeletePIIPolicy(&policy)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to delete PII policy. %v", err.Error()),
+		})
+		return
+	}
+
+	c.Set("piiPolicy", *res)
+	c.JSON(http.StatusOK, *res)
+}
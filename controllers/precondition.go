@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"user-storage/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag is the strong entity tag of a record version.
+func etag(version int) string {
+	return fmt.Sprintf("%q", fmt.Sprint(version))
+}
+
+// requireIfMatch reports whether writes must carry If-Match, set by
+// REQUIRE_IF_MATCH.
+func requireIfMatch() bool {
+	return strings.EqualFold(os.Getenv("REQUIRE_IF_MATCH"), "true")
+}
+
+// checkIfMatch compares the If-Match header with the current version of the
+// record being written. It responds 412 when no tag matches, or 428 when the
+// header is missing and REQUIRE_IF_MATCH is set, and reports whether the write
+// may go ahead.
+func checkIfMatch(c *gin.Context, version int) bool {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if requireIfMatch() {
+			c.JSON(http.StatusPreconditionRequired, models.HTTPError{
+				Code:    http.StatusPreconditionRequired,
+				Message: "If-Match header is required",
+			})
+			return false
+		}
+		return true
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	c.JSON(http.StatusPreconditionFailed, models.HTTPError{
+		Code:    http.StatusPreconditionFailed,
+		Message: fmt.Sprintf("Record has changed, its current ETag is %s", current),
+	})
+	return false
+}
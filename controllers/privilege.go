@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"user-storage/models"
+	"user-storage/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// callerId returns the user_id claim DecodeJWT stored for the request.
+func callerId(c *gin.Context) string {
+	data, _ := c.Get("userDetails")
+	userDetailsObj, _ := data.(map[string]interface{})
+	userId, _ := userDetailsObj["user_id"].(string)
+	return userId
+}
+
+// privilegeError builds the response for a failed privilege check, carrying
+// the reason code when the check refused the change.
+func privilegeError(code int, message string, err error) models.HTTPError {
+	return models.HTTPError{
+		Code:    code,
+		Message: message + " " + err.Error(),
+		Reason:  services.PrivilegeReason(err),
+	}
+}
@@ -1,9 +1,12 @@
 package controllers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"user-storage/models"
+	"user-storage/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,12 +17,24 @@ type RoleController struct{}
 //  @Description    Retrieves a list of Roles 
 //  @Tags           roles
 //  @Produce        json
+//  @Param          createdBy       query   string  false   "Only rows created by this user id"
+//  @Param          updatedBy       query   string  false   "Only rows last changed by this user id"
+//  @Param          createdAfter    query   string  false   "Only rows created at or after this RFC 3339 time"
+//  @Param          createdBefore   query   string  false   "Only rows created before this RFC 3339 time"
+//  @Param          updatedAfter    query   string  false   "Only rows changed at or after this RFC 3339 time"
+//  @Param          updatedBefore   query   string  false   "Only rows changed before this RFC 3339 time"
+//  @Param          sort            query   string  false   "Comma separated createdAt, updatedAt, createdBy or updatedBy, each prefixed with - to descend"
 //  @Success        200     {array}     models.Role
+//  @Failure        400     {object}    models.HTTPError    "Invalid parameters"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /roles  [get]
 func (t RoleController) GetAllRoles(c *gin.Context) {
 	var roles []models.Role
-	err := models.DB.Find(&roles)
+	stamps, ok := stampFilter(c, services.StampSortKeys)
+	if !ok {
+		return
+	}
+	err := stamps.Apply(models.DB).Find(&roles)
 	if err.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.HTTPError{
 			Code:    http.StatusInternalServerError,
@@ -36,6 +51,7 @@ func (t RoleController) GetAllRoles(c *gin.Context) {
 //  @Produce        json
 //  @Param          id      path    string  true    "id"
 //  @Success        200     {object}    models.Role
+//  @Header         200     {string}    ETag    "Version of the role"
 //  @Failure        400     {object}    models.HTTPError    "RoleId cannot be empy"
 //  @Failure        404     {object}    models.HTTPError    "Role not found with Id"
 //  @Failure        500     {object}    models.HTTPError
@@ -60,9 +76,42 @@ func (t RoleController) GetRoleByID(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", etag(roles.Version))
 	c.JSON(http.StatusOK, roles)
 }
 
+//  @Summary        Get access granted to a Role
+//  @Description    Retrieve the Role's direct role_access grants and those inherited from its ancestors
+//  @Tags           roles
+//  @Produce        json
+//  @Param          id      path    string  true    "id"
+//  @Success        200     {array}     models.Permission
+//  @Failure        400     {object}    models.HTTPError    "RoleId must be a number"
+//  @Failure        404     {object}    models.HTTPError    "Role not found with Id"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /roles/{id}/access   [get]
+func (t RoleController) GetRoleAccess(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: "Role ID must be a number",
+		})
+		return
+	}
+
+	grants, code, err := services.NewRoleService(models.DB).GetRoleGrants(id)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Error getting data. %v", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, *grants)
+}
+
 //  @Summary        Add a Role
 //  @Description    Add a Role into Database
 //  @Tags           roles
@@ -70,6 +119,7 @@ func (t RoleController) GetRoleByID(c *gin.Context) {
 //  @Param          role    body        models.Role     true    "Role Details"
 //  @Success        200     {object}    models.Role
 //  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body"
+//  @Failure        403     {object}    models.HTTPError    "Rank or parent is ranked above the caller, or the caller holds no ranked role"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /roles  [post]
 func (t RoleController) AddRole(c *gin.Context) {
@@ -81,6 +131,7 @@ func (t RoleController) AddRole(c *gin.Context) {
 		})
 		return
 	}
+	role.Stamps = models.Stamps{}
 	if err := validate.Struct(&role); err != nil {
 		c.JSON(http.StatusBadRequest, models.HTTPError{
 			Code:    http.StatusBadRequest,
@@ -88,7 +139,27 @@ func (t RoleController) AddRole(c *gin.Context) {
 		})
 		return
 	}
-	if result := models.DB.Create(&role); result.Error != nil {
+	if code, err := services.NewRoleService(models.DB).ValidateParent(0, role.ParentId); err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to create role. %v", err.Error()),
+		})
+		return
+	}
+
+	// A role may not outrank its creator, nor inherit from a role that does
+	privileges := services.NewPrivilegeService(models.DB)
+	if code, err := privileges.CheckRank(callerId(c), role.Rank); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to create role.", err))
+		return
+	}
+	if role.ParentId != nil {
+		if code, err := privileges.CheckRoleRanks(callerId(c), *role.ParentId); err != nil {
+			c.JSON(code, privilegeError(code, "Unable to create role.", err))
+			return
+		}
+	}
+	if result := models.DB.WithContext(c.Request.Context()).Create(&role); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.HTTPError{
 			Code:    http.StatusInternalServerError,
 			Message: fmt.Sprintf("Unable to create role. %v", result.Error.Error()),
@@ -100,13 +171,17 @@ func (t RoleController) AddRole(c *gin.Context) {
 }
 
 //  @Summary        Update Role Details by Id
-//  @Description    Update a Role By RoleId
+//  @Description    Update a Role By RoleId. rank and parentId keep their current values when left out
 //  @Tags           roles
 //  @Produce        json
 //  @Param          id      path    string  true    "id"
+//  @Param          If-Match    header  string  false   "ETag of the role the change is based on"
 //  @Success        200     {object}    models.Role
 //  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body"
+//  @Failure        403     {object}    models.HTTPError    "Role, its new rank or its parent is ranked above the caller, or the caller holds no ranked role"
 //  @Failure        404     {object}    models.HTTPError    "Role not found with Id"
+//  @Failure        412     {object}    models.HTTPError    "If-Match does not match the current ETag"
+//  @Failure        428     {object}    models.HTTPError    "If-Match is required when REQUIRE_IF_MATCH is set"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /roles/{id}   [put]
 func (t RoleController) UpdateRoleById(c *gin.Context) {
@@ -119,13 +194,22 @@ func (t RoleController) UpdateRoleById(c *gin.Context) {
 		})
 		return
 	}
-	if err := c.BindJSON(&role); err != nil {
+	body, err := c.GetRawData()
+	var present map[string]json.RawMessage
+	if err == nil {
+		err = json.Unmarshal(body, &present)
+	}
+	if err == nil {
+		err = json.Unmarshal(body, &role)
+	}
+	if err != nil {
 		c.JSON(http.StatusBadRequest, models.HTTPError{
 			Code:    http.StatusBadRequest,
 			Message: fmt.Sprintf("Missing required fields. %v", err.Error()),
 		})
 		return
 	}
+	role.Stamps = models.Stamps{}
 	if err := validate.Struct(&role); err != nil {
 		c.JSON(http.StatusBadRequest, models.HTTPError{
 			Code:    http.StatusBadRequest,
@@ -144,16 +228,67 @@ func (t RoleController) UpdateRoleById(c *gin.Context) {
 		return
 	}
 	c.Set("role", existingRole)
+	if !checkIfMatch(c, existingRole.Version) {
+		return
+	}
+	if role.Version != 0 && role.Version != existingRole.Version {
+		c.JSON(http.StatusPreconditionFailed, models.HTTPError{
+			Code:    http.StatusPreconditionFailed,
+			Message: "Unable to update role. Role was modified by someone else",
+		})
+		return
+	}
+	// Leaving rank or parentId out keeps them, rather than demoting the role
+	// to rank 0 or detaching it from the hierarchy. "parentId": null detaches
+	if _, ok := present["rank"]; !ok {
+		role.Rank = existingRole.Rank
+	}
+	if _, ok := present["parentId"]; !ok {
+		role.ParentId = existingRole.ParentId
+	}
+
+	if code, err := services.NewRoleService(models.DB).ValidateParent(existingRole.Id, role.ParentId); err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to update role. %v", err.Error()),
+		})
+		return
+	}
+
+	privileges := services.NewPrivilegeService(models.DB)
+	roleIds := []int{existingRole.Id}
+	if role.ParentId != nil {
+		roleIds = append(roleIds, *role.ParentId)
+	}
+	if code, err := privileges.CheckRoleRanks(callerId(c), roleIds...); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to update role.", err))
+		return
+	}
+	if code, err := privileges.CheckRank(callerId(c), role.Rank); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to update role.", err))
+		return
+	}
 
-	if result := models.DB.Model(&existingRole).Updates(&role); result.Error != nil {
+	role.Version = existingRole.Version + 1
+	result := models.DB.WithContext(c.Request.Context()).Model(&existingRole).Where("version = ?", existingRole.Version).
+		Select("Name", "ParentId", "Rank", "Version").Updates(&role)
+	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.HTTPError{
 			Code:    http.StatusInternalServerError,
 			Message: fmt.Sprintf("Unable to update role. %v", result.Error.Error()),
 		})
 		return
 	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusPreconditionFailed, models.HTTPError{
+			Code:    http.StatusPreconditionFailed,
+			Message: "Unable to update role. Role was modified by someone else",
+		})
+		return
+	}
 
 	c.Set("updatedRole", role)
+	c.Header("ETag", etag(existingRole.Version))
 	c.JSON(http.StatusOK, existingRole)
 }
 
@@ -162,9 +297,13 @@ func (t RoleController) UpdateRoleById(c *gin.Context) {
 //  @Tags           roles
 //  @Produce        json
 //  @Param          id      path    string  true    "id"
+//  @Param          If-Match    header  string  false   "ETag of the role the change is based on"
 //  @Success        200     "Success"   
 //  @Failure        400     {object}    models.HTTPError    "Bad request due to empty string Id"
+//  @Failure        403     {object}    models.HTTPError    "Role is ranked above the caller, or the caller holds no ranked role"
 //  @Failure        404     {object}    models.HTTPError    "Role not found with Id"
+//  @Failure        412     {object}    models.HTTPError    "If-Match does not match the current ETag"
+//  @Failure        428     {object}    models.HTTPError    "If-Match is required when REQUIRE_IF_MATCH is set"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /roles/{id}   [delete]
 func (t RoleController) DeleteRoleById(c *gin.Context) {
@@ -184,6 +323,29 @@ func (t RoleController) DeleteRoleById(c *gin.Context) {
 		})
 		return
 	}
+	if !checkIfMatch(c, role.Version) {
+		return
+	}
+	if code, err := services.NewPrivilegeService(models.DB).CheckRoleRanks(callerId(c), role.Id); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to delete role.", err))
+		return
+	}
+
+	hasChildren, code, err := services.NewRoleService(models.DB).HasChildren(role.Id)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to delete role. %v", err.Error()),
+		})
+		return
+	}
+	if hasChildren {
+		c.JSON(http.StatusConflict, models.HTTPError{
+			Code:    http.StatusConflict,
+			Message: "Unable to delete role. Other roles inherit from it",
+		})
+		return
+	}
 	if result := models.DB.Delete(&role); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.HTTPError{
 			Code:    http.StatusInternalServerError,
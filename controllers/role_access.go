@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"user-storage/models"
+	"user-storage/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,12 +15,24 @@ type RoleAccessController struct {}
 //  @Description    Retrieves a list of Role Access
 //  @Tags           role-access
 //  @Produce        json
+//  @Param          createdBy       query   string  false   "Only rows created by this user id"
+//  @Param          updatedBy       query   string  false   "Only rows last changed by this user id"
+//  @Param          createdAfter    query   string  false   "Only rows created at or after this RFC 3339 time"
+//  @Param          createdBefore   query   string  false   "Only rows created before this RFC 3339 time"
+//  @Param          updatedAfter    query   string  false   "Only rows changed at or after this RFC 3339 time"
+//  @Param          updatedBefore   query   string  false   "Only rows changed before this RFC 3339 time"
+//  @Param          sort            query   string  false   "Comma separated createdAt, updatedAt, createdBy or updatedBy, each prefixed with - to descend"
 //  @Success        200     {array}     models.RoleAccess
+//  @Failure        400     {object}    models.HTTPError    "Invalid parameters"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /role-access  [get]
 func (t RoleAccessController) GetAllRoleAccesses(c *gin.Context) {
 	var roleAccesses []models.RoleAccess
-	err := models.DB.Find(&roleAccesses)
+	stamps, ok := stampFilter(c, services.StampSortKeys)
+	if !ok {
+		return
+	}
+	err := stamps.Apply(models.DB).Find(&roleAccesses)
 	if err.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.HTTPError{
 			Code: http.StatusInternalServerError,
@@ -31,12 +44,14 @@ func (t RoleAccessController) GetAllRoleAccesses(c *gin.Context) {
 }
 
 //  @Summary        Add a Role Access
-//  @Description    Add a Role Access into Database
+//  @Description    Add a Role Access into Database, optionally restricted by conditions such as "owner && ip in 10.0.0.0/8 && hours 09:00-18:00 Asia/Singapore"
 //  @Tags           role-access
 //  @Produce        json
 //  @Param          role-access    body        models.RoleAccess     true    "Role Access Details"
 //  @Success        200     {object}    models.RoleAccess
-//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body"
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body or conditions"
+//  @Failure        403     {object}    models.HTTPError    "Only a super-admin can grant admin access points"
+//  @Failure        404     {object}    models.HTTPError    "Access point is not found"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /role-access [post]
 func (t RoleAccessController) AddRoleAccess(c *gin.Context) {
@@ -49,6 +64,7 @@ func (t RoleAccessController) AddRoleAccess(c *gin.Context) {
 		})
 		return
 	}
+	roleAccess.Stamps = models.Stamps{}
 	if err := validate.Struct(&roleAccess); err != nil {
 		c.JSON(http.StatusBadRequest, models.HTTPError{
 			Code: http.StatusBadRequest,
@@ -56,13 +72,25 @@ func (t RoleAccessController) AddRoleAccess(c *gin.Context) {
 		})
 		return
 	}
-	if result := models.DB.Create(&roleAccess); result.Error != nil {
+	if code, err := services.NewPrivilegeService(models.DB).CheckAccessPoints(callerId(c), roleAccess.APId); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to create role access.", err))
+		return
+	}
+	if code, err := services.ValidateGrantConditions(models.DB, &roleAccess); err != nil {
+		c.JSON(code, models.HTTPError{
+			Code: code,
+			Message: fmt.Sprintf("Unable to create role access. %v" , err.Error()),
+		})
+		return
+	}
+	if result := models.DB.WithContext(c.Request.Context()).Create(&roleAccess); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.HTTPError{
 			Code: http.StatusInternalServerError,
 			Message: fmt.Sprintf("Unable to create role access. %v" , result.Error.Error()),
 		})
 		return
 	}
+	c.Set("roleAccess", roleAccess)
 	c.JSON(http.StatusOK, roleAccess)
 }
 
@@ -74,6 +102,7 @@ func (t RoleAccessController) AddRoleAccess(c *gin.Context) {
 //  @Param          ap_id      body    string  true    "Access Point ID"
 //  @Success        200     "Success"   
 //  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON object"
+//  @Failure        403     {object}    models.HTTPError    "Only a super-admin can revoke admin access points"
 //  @Failure        404     {object}    models.HTTPError    "Role access is not found given role_id and ap_id"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /role-access   [delete]
@@ -105,6 +134,11 @@ func (t RoleAccessController) DeleteRoleAccess(c *gin.Context) {
 		return
 	}
 
+	if code, err := services.NewPrivilegeService(models.DB).CheckAccessPoints(callerId(c), roleAccess.APId); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to delete role access.", err))
+		return
+	}
+
 	if result := models.DB.Where("role_id = ? AND ap_id = ?", roleAccess.RoleId, roleAccess.APId).Delete(&existingRoleAccess); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.HTTPError{
 			Code: http.StatusInternalServerError,
@@ -113,6 +147,7 @@ func (t RoleAccessController) DeleteRoleAccess(c *gin.Context) {
 		return
 	}
 
+	c.Set("roleAccess", existingRoleAccess)
 	c.JSON(http.StatusOK, "Success")
 }
 
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"user-storage/models"
+	"user-storage/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RoleConflictController struct {
+	RoleConflictService *services.RoleConflictService
+}
+
+func NewRoleConflictController(db *gorm.DB) *RoleConflictController {
+	return &RoleConflictController{
+		RoleConflictService: services.NewRoleConflictService(db),
+	}
+}
+
+//  @Summary        Get all Role Conflicts
+//  @Description    Retrieves the sets of roles that no user may hold together
+//  @Tags           role-conflicts
+//  @Produce        json
+//  @Success        200     {array}     models.RoleConflict
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /role-conflicts  [get]
+func (t RoleConflictController) GetAllRoleConflicts(c *gin.Context) {
+	res, code, err := t.RoleConflictService.GetAllRoleConflicts()
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Error getting data. %v", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, *res)
+}
+
+//  @Summary        Add a Role Conflict
+//  @Description    Define a set of mutually exclusive roles. Existing holders are reported by /role-conflicts/violations
+//  @Tags           role-conflicts
+//  @Produce        json
+//  @Param          role-conflict    body        models.RoleConflict     true    "Role Conflict Details"
+//  @Success        201     {object}    models.RoleConflict
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body or unknown role"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /role-conflicts [post]
+func (t RoleConflictController) AddRoleConflict(c *gin.Context) {
+	var conflict models.RoleConflict
+	if err := c.BindJSON(&conflict); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid JSON request: %v", err.Error()),
+		})
+		return
+	}
+
+	res, code, err := t.RoleConflictService.AddRoleConflict(&conflict)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to create role conflict. %v", err.Error()),
+		})
+		return
+	}
+
+	c.Set("roleConflict", *res)
+	c.JSON(code, *res)
+}
+
+//  @Summary        Delete a Role Conflict
+//  @Description    Delete a Role Conflict by Id
+//  @Tags           role-conflicts
+//  @Produce        json
+//  @Param          id      path    int     true    "id"
+//  @Success        200     {object}    models.RoleConflict
+//  @Failure        404     {object}    models.HTTPError    "Role conflict not found with Id"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /role-conflicts/{id}   [delete]
+func (t RoleConflictController) DeleteRoleConflict(c *gin.Context) {
+	id := c.Param("id")
+
+	res, code, err := t.RoleConflictService.DeleteRoleConflict(id)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to delete role conflict. %v", err.Error()),
+		})
+		return
+	}
+
+	c.Set("roleConflict", *res)
+	c.JSON(http.StatusOK, *res)
+}
+
+//  @Summary        Report Role Conflict violations
+//  @Description    Lists every user currently holding more than one role of the same conflict
+//  @Tags           role-conflicts
+//  @Produce        json
+//  @Success        200     {array}     models.RoleConflictViolation
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /role-conflicts/violations  [get]
+func (t RoleConflictController) GetViolations(c *gin.Context) {
+	res, code, err := t.RoleConflictService.GetViolations()
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Error getting data. %v", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, *res)
+}
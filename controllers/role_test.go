@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var roleColumns = []string{"id", "name", "parent_id", "role_rank", "version"}
+
+func TestUpdateRoleById_KeepsOmittedFields(t *testing.T) {
+    db, mock := newMockDB(t)
+    models.DB = db
+    role := new(RoleController)
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `roles` WHERE id = ?")).
+        WithArgs("3").
+        WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "Manager", 1, 50, 2))
+    // The kept parent is still validated
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `roles` WHERE id = ?")).
+        WithArgs(1).
+        WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(1, "Admin", nil, 100, 1))
+    for i := 0; i < 2; i++ {
+        mock.ExpectQuery(regexp.QuoteMeta("SELECT `roles`.`id`,`roles`.`name`")).
+            WithArgs("9", sqlmock.AnyArg(), sqlmock.AnyArg()).
+            WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(10, "Super Admin", nil, 1000, 1))
+    }
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE `roles` SET `name`=?,`parent_id`=?,`role_rank`=?,`version`=?")).
+        WithArgs("Managers", 1, 50, 3, sqlmock.AnyArg(), 2, 3).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+
+    gin.SetMode(gin.TestMode)
+    router := gin.New()
+    router.PUT("/roles/:id", func(c *gin.Context) {
+        c.Set("userDetails", map[string]interface{}{"user_id": "9"})
+    }, role.UpdateRoleById)
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/roles/3", strings.NewReader(`{"name":"Managers"}`)))
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
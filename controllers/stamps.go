@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"user-storage/models"
+	"user-storage/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stampFilter reads the createdBy, updatedBy, createdAfter, createdBefore,
+// updatedAfter, updatedBefore and sort query parameters listings share. Times
+// are RFC 3339 and sort may use sortKeys. It writes the error response itself.
+func stampFilter(c *gin.Context, sortKeys map[string]string) (services.StampFilter, bool) {
+	filter := services.StampFilter{
+		CreatedBy: c.Query("createdBy"),
+		UpdatedBy: c.Query("updatedBy"),
+	}
+
+	times := map[string]**time.Time{
+		"createdAfter":  &filter.CreatedAfter,
+		"createdBefore": &filter.CreatedBefore,
+		"updatedAfter":  &filter.UpdatedAfter,
+		"updatedBefore": &filter.UpdatedBefore,
+	}
+	for param, dest := range times {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.HTTPError{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid %s parameter, expected an RFC 3339 time", param),
+			})
+			return filter, false
+		}
+		*dest = &parsed
+	}
+
+	sort, err := services.ParseSort(c.Query("sort"), sortKeys)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid sort parameter. %v", err.Error()),
+		})
+		return filter, false
+	}
+	filter.Sort = sort
+	filter.SortKeys = sortKeys
+	return filter, true
+}
@@ -3,9 +3,12 @@ package controllers
 import (
 	// "context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	// "user-storage/cache"
 	"user-storage/models"
 	"user-storage/services"
@@ -17,66 +20,198 @@ import (
 )
 
 type UserController struct {
-	DB          *gorm.DB
-	UserService *services.UserService
+	DB                 *gorm.DB
+	UserService        *services.UserService
+	AccessService      *services.AccessService
+	DataScopeService   *services.DataScopeService
+	PrivilegeService   *services.PrivilegeService
+	FieldPolicyService *services.FieldPolicyService
+	PIIService         *services.PIIService
 }
 
 func NewUserController(db gorm.DB) *UserController {
 	return &UserController{
-		DB:          &db,
-		UserService: services.NewUserService(&db),
+		DB:                 &db,
+		UserService:        services.NewUserService(&db),
+		AccessService:      services.NewAccessService(&db),
+		DataScopeService:   services.NewDataScopeService(&db),
+		PrivilegeService:   services.NewPrivilegeService(&db),
+		FieldPolicyService: services.NewFieldPolicyService(&db),
+		PIIService:         services.NewPIIService(&db),
 	}
 }
 
+// scopedUserService returns the UserService limited to the users the caller's
+// data scopes allow them to see. It writes the error response itself.
+func (t UserController) scopedUserService(c *gin.Context) (*services.UserService, bool) {
+	data, ok := c.Get("userDetails")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, models.HTTPError{
+			Code:    http.StatusInternalServerError,
+			Message: "Error",
+		})
+		return nil, false
+	}
+	userDetailsObj, ok := data.(map[string]interface{})
+	if !ok {
+		c.JSON(http.StatusInternalServerError, models.HTTPError{
+			Code:    http.StatusInternalServerError,
+			Message: "Error",
+		})
+		return nil, false
+	}
+	userId, _ := userDetailsObj["user_id"].(string)
+
+	scope, code, err := t.DataScopeService.ScopeForUser(userId)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to resolve data scope. %v", err.Error()),
+		})
+		return nil, false
+	}
+	return t.UserService.WithScope(scope), true
+}
+
+// redaction returns how the caller's PII policies shape the users in
+// responses. It writes the error response itself.
+func (t UserController) redaction(c *gin.Context) (services.Redaction, bool) {
+	redaction, code, err := t.PIIService.RedactionForUser(callerId(c))
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to resolve PII policy. %v", err.Error()),
+		})
+		return nil, false
+	}
+	return redaction, true
+}
+
+// filterable refuses filters and sorts on fields redaction hides. It writes
+// the error response itself.
+func filterable(c *gin.Context, redaction services.Redaction, filter services.UserFilter) bool {
+	if fields := redaction.FilteredFields(filter); len(fields) > 0 {
+		c.JSON(http.StatusForbidden, models.HTTPError{
+			Code:    http.StatusForbidden,
+			Message: fmt.Sprintf("Not allowed to filter or sort by %s, which is redacted for you", strings.Join(fields, ", ")),
+			Reason:  services.ReasonRedactedField,
+		})
+		return false
+	}
+	return true
+}
+
+// withDeleted widens userService to soft-deleted users when the caller asks
+// for ?includeDeleted=true, which only admins may. It writes the error
+// response itself.
+func (t UserController) withDeleted(c *gin.Context, userService *services.UserService) (*services.UserService, bool) {
+	if c.Query("includeDeleted") != "true" {
+		return userService, true
+	}
+	isAdmin, code, err := t.AccessService.IsAdmin(callerId(c))
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to resolve access. %v", err.Error()),
+		})
+		return nil, false
+	}
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, models.HTTPError{
+			Code:    http.StatusForbidden,
+			Message: "Only admins may include deleted users",
+		})
+		return nil, false
+	}
+	return userService.WithDeleted(), true
+}
+
+// userFilter reads the filters user listings share: the id, role, name and
+// email prefixes, the stamp filters and a SCIM filter expression. It writes
+// the error response itself.
+func userFilter(c *gin.Context) (services.UserFilter, bool) {
+	filter := services.UserFilter{
+		Id:    c.Query("id"),
+		Name:  c.Query("name"),
+		Email: c.Query("email"),
+	}
+
+	role, err := strconv.Atoi(c.DefaultQuery("role", "-1"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid role parameter",
+		})
+		return filter, false
+	}
+	filter.Role = role
+
+	stamps, ok := stampFilter(c, services.UserSortKeys)
+	if !ok {
+		return filter, false
+	}
+	filter.Stamps = stamps
+
+	if raw := c.Query("filter"); raw != "" {
+		expression, err := services.ParseFilter(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.HTTPError{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid filter parameter. %v", err.Error()),
+				Reason:  services.ReasonInvalidFilter,
+			})
+			return filter, false
+		}
+		filter.Expression = expression
+	}
+	return filter, true
+}
+
 var validate = validator.New()
 
 
 //  @Summary        Get all Users
-//  @Description    Retrieves a list of users
+//  @Description    Retrieves a list of users within the caller's data scope, with PII shaped by the caller's PII policies
 //  @Tags           users
 //  @Produce        json
+//  @Param          id      query   string  false   "Only ids starting with this"
+//  @Param          role    query   int     false   "Only users holding this role, or 0 for users without a role"
+//  @Param          name    query   string  false   "Only first or last names starting with this"
+//  @Param          email   query   string  false   "Only emails starting with this"
+//  @Param          filter          query   string  false   "SCIM filter over id, firstName, lastName, email, role, createdAt, updatedAt, createdBy and updatedBy, such as role eq 2 and (email ew \"@bank.com\" or lastName sw \"Ta\")"
+//  @Param          includeDeleted  query   bool    false   "Include soft-deleted users (admins only)"
+//  @Param          createdBy       query   string  false   "Only rows created by this user id"
+//  @Param          updatedBy       query   string  false   "Only rows last changed by this user id"
+//  @Param          createdAfter    query   string  false   "Only rows created at or after this RFC 3339 time"
+//  @Param          createdBefore   query   string  false   "Only rows created before this RFC 3339 time"
+//  @Param          updatedAfter    query   string  false   "Only rows changed at or after this RFC 3339 time"
+//  @Param          updatedBefore   query   string  false   "Only rows changed before this RFC 3339 time"
+//  @Param          sort            query   string  false   "Comma separated id, firstName, lastName, email, createdAt, updatedAt, createdBy or updatedBy, each prefixed with - to descend"
 //  @Success        200     {array}     models.User
+//  @Failure        400     {object}    models.HTTPError    "Invalid parameters or filter"
+//  @Failure        403     {object}    models.HTTPError    "includeDeleted requested by a non-admin, or a filter or sort on a field redacted for the caller"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /accounts   [get]
 func (t UserController) GetAllUsers(c *gin.Context) {
-	id := c.DefaultQuery("id", "")
-	role := c.DefaultQuery("role", "-1")
-	name := c.DefaultQuery("name", "")
-	email := c.DefaultQuery("email", "")
-
-	data, ok := c.Get("userDetails")
+	filter, ok := userFilter(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, models.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: "Error",
-		})
 		return
+	}
 
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
 	}
-	userDetailsObj, ok := data.(map[string]interface{})
+	userService, ok = t.withDeleted(c, userService)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, models.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: "Error",
-		})
 		return
 	}
-	fmt.Println("User Role: ", userDetailsObj["role"])
-
-	// Check if user is product manager
-	if userDetailsObj["role"] == "Product Manager" {
-		role = "0"
+	redaction, ok := t.redaction(c)
+	if !ok || !filterable(c, redaction, filter) {
+		return
 	}
 
-	roleInt, err := strconv.Atoi(role)
-    if err != nil {
-        c.JSON(http.StatusBadRequest, models.HTTPError{
-            Code:    http.StatusBadRequest,
-            Message: "Invalid role parameter",
-        })
-        return
-    }
-	users, code, err := t.UserService.GetAllUsers(roleInt, id, name ,email)
+	users, code, err := userService.GetAllUsers(filter)
 	if err != nil {
 		c.JSON(code, models.HTTPError{
 			Code:    code,
@@ -84,43 +219,78 @@ func (t UserController) GetAllUsers(c *gin.Context) {
 		})
 		return
 	}
-	c.JSON(code, *users)
+	c.JSON(code, redaction.Users(*users))
 }
 
 //  @Summary        Get all Users by Pagination
-//  @Description    Retrieves a list of users
+//  @Description    Retrieves a page of users within the caller's data scope in a stable order. Pass back pagination.next or pagination.prev as cursor, with the same sort, to move between pages
 //  @Tags           users
 //  @Produce        json
-//  @Param          page    query   int     true    "page"
-//  @Param          size    query   int     true    "size"
-//  @Success        200     {array}     models.User
-//  @Failure        400     {object}    models.HTTPError    "Invalid parameters"
+//  @Param          size    query   int     false   "Page size, up to PAGE_SIZE_MAX"   default(10)
+//  @Param          cursor  query   string  false   "Token from a previous page"
+//  @Param          total   query   bool    false   "Also count every matching user"
+//  @Param          id      query   string  false   "Only ids starting with this"
+//  @Param          role    query   int     false   "Only users holding this role, or 0 for users without a role"
+//  @Param          name    query   string  false   "Only first or last names starting with this"
+//  @Param          email   query   string  false   "Only emails starting with this"
+//  @Param          filter          query   string  false   "SCIM filter over id, firstName, lastName, email, role, createdAt, updatedAt, createdBy and updatedBy, such as role eq 2 and (email ew \"@bank.com\" or lastName sw \"Ta\")"
+//  @Param          includeDeleted  query   bool    false   "Include soft-deleted users (admins only)"
+//  @Param          createdBy       query   string  false   "Only rows created by this user id"
+//  @Param          updatedBy       query   string  false   "Only rows last changed by this user id"
+//  @Param          createdAfter    query   string  false   "Only rows created at or after this RFC 3339 time"
+//  @Param          createdBefore   query   string  false   "Only rows created before this RFC 3339 time"
+//  @Param          updatedAfter    query   string  false   "Only rows changed at or after this RFC 3339 time"
+//  @Param          updatedBefore   query   string  false   "Only rows changed before this RFC 3339 time"
+//  @Param          sort            query   string  false   "Comma separated id, firstName, lastName, email, createdAt, updatedAt, createdBy or updatedBy, each prefixed with - to descend"
+//  @Success        200     {object}    models.UserPage
+//  @Failure        400     {object}    models.HTTPError    "Invalid parameters, filter or cursor"
+//  @Failure        403     {object}    models.HTTPError    "includeDeleted requested by a non-admin, or a filter or sort on a field redacted for the caller"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /accounts/paginate   [get]
 func (t UserController) GetPaginatedUsers(c *gin.Context) {
-	page := c.DefaultQuery("page", "1")
-	pageSize := c.DefaultQuery("size", "10")
-
-	// Convert page and pageSize to integers
-	pageInt, err := strconv.Atoi(page)
-	if err != nil {
+	// Offsets shift as users are added, so pages are only reached by cursor
+	if page := c.Query("page"); page != "" && page != "1" {
 		c.JSON(http.StatusBadRequest, models.HTTPError{
 			Code:    http.StatusBadRequest,
-			Message: "Invalid page parameter",
+			Message: "Invalid page parameter, follow pagination.next as cursor instead",
 		})
 		return
 	}
 
-	pageSizeInt, err := strconv.Atoi(pageSize)
+	size, err := strconv.Atoi(c.DefaultQuery("size", "10"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.HTTPError{
 			Code:    http.StatusBadRequest,
-			Message: "Invalid pageSize parameter",
+			Message: "Invalid size parameter",
 		})
 		return
 	}
+	filter, ok := userFilter(c)
+	if !ok {
+		return
+	}
 
-	users, code, err := t.UserService.GetPaginatedUsers(pageInt, pageSizeInt)
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	userService, ok = t.withDeleted(c, userService)
+	if !ok {
+		return
+	}
+	redaction, ok := t.redaction(c)
+	if !ok || !filterable(c, redaction, filter) {
+		return
+	}
+
+	request := services.PageRequest{
+		Size:   size,
+		Cursor: c.Query("cursor"),
+		Sort:   filter.Stamps.Sort,
+		Total:  c.Query("total") == "true",
+	}
+
+	page, code, err := userService.GetPaginatedUsers(filter, request)
 	if err != nil {
 		c.JSON(code, models.HTTPError{
 			Code:    code,
@@ -128,26 +298,47 @@ func (t UserController) GetPaginatedUsers(c *gin.Context) {
 		})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"data":       users,
-		"pagination": gin.H{"page": page, "size": pageSize},
+	c.JSON(http.StatusOK, models.UserPage{
+		Data: redaction.Users(page.Users),
+		Pagination: models.Pagination{
+			Size:  size,
+			Next:  page.Next,
+			Prev:  page.Prev,
+			Total: page.Total,
+		},
 	})
 }
 
 //  @Summary        Get User by Id
-//  @Description    Retrieve a User By UserID
+//  @Description    Retrieve a User By UserID. Users outside the caller's data scope are not found
 //  @Tags           users
 //  @Produce        json
 //  @Param          id      path    string  true    "id"
+//  @Param          includeDeleted  query   bool    false   "Also find a soft-deleted user (admins only)"
 //  @Success        200     {array}     models.User
+//  @Header         200     {string}    ETag    "Version of the user"
 //  @Failure        400     {object}    models.HTTPError    "UserId cannot be empy"
+//  @Failure        403     {object}    models.HTTPError    "includeDeleted requested by a non-admin"
 //  @Failure        404     {object}    models.HTTPError    "User not found with Id"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /accounts/{id}   [get]
 func (t UserController) GetUserByID(c *gin.Context) {
 	id := c.Param("id")
 
-	user, code, err := t.UserService.GetUserByID(id)
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	userService, ok = t.withDeleted(c, userService)
+	if !ok {
+		return
+	}
+	redaction, ok := t.redaction(c)
+	if !ok {
+		return
+	}
+
+	user, code, err := userService.GetUserByID(id)
 	if err != nil {
 		c.JSON(code, models.HTTPError{
 			Code:    code,
@@ -156,16 +347,273 @@ func (t UserController) GetUserByID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, *user)
+	c.Header("ETag", etag(user.Version))
+	c.JSON(http.StatusOK, redaction.User(*user))
+}
+
+//  @Summary        Get User by email
+//  @Description    Retrieve the User with exactly this email, once normalized. Users outside the caller's data scope are not found
+//  @Tags           users
+//  @Produce        json
+//  @Param          email   path    string  true    "email"
+//  @Success        200     {object}    models.User
+//  @Header         200     {string}    ETag    "Version of the user"
+//  @Failure        400     {object}    models.HTTPError    "Email is malformed"
+//  @Failure        403     {object}    models.HTTPError    "Email is redacted for the caller"
+//  @Failure        404     {object}    models.HTTPError    "User not found with email"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /accounts/by-email/{email}   [get]
+func (t UserController) GetUserByEmail(c *gin.Context) {
+	email := c.Param("email")
+
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	redaction, ok := t.redaction(c)
+	if !ok {
+		return
+	}
+
+	user, code, err := userService.WithRedaction(redaction).GetUserByEmail(email)
+	if err != nil {
+		response := models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Failed to retrieve user: %v", err.Error()),
+		}
+		if errors.Is(err, services.ErrEmailRedacted) {
+			response.Reason = services.ReasonRedactedField
+		}
+		c.JSON(code, response)
+		return
+	}
+
+	c.Header("ETag", etag(user.Version))
+	c.JSON(http.StatusOK, redaction.User(*user))
+}
+
+//  @Summary        Search Users
+//  @Description    Find users within the caller's data scope by name or email, best matches first. Every word of q has to match, ignoring case and accents, and q needs a word of at least 3 letters or digits. Fields the caller's PII policies hide are not searched
+//  @Tags           users
+//  @Produce        json
+//  @Param          q       query   string  true    "Words to search for"
+//  @Param          limit   query   int     false   "Most results to return, up to PAGE_SIZE_MAX"   default(20)
+//  @Success        200     {array}     models.UserSearchResult
+//  @Failure        400     {object}    models.HTTPError    "Query without a word of at least 3 letters or digits, or invalid limit"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /accounts/search   [get]
+func (t UserController) SearchUsers(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid limit parameter",
+		})
+		return
+	}
+
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	redaction, ok := t.redaction(c)
+	if !ok {
+		return
+	}
+
+	results, code, err := userService.SearchUsers(c.Query("q"), limit, redaction)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to search users. %v", err.Error()),
+		})
+		return
+	}
+	for i := range *results {
+		(*results)[i].User = redaction.User((*results)[i].User)
+	}
+	c.JSON(http.StatusOK, *results)
+}
+
+//  @Summary        Get effective permissions of a User
+//  @Description    Retrieve every access point the User's role is granted, with the grant that produced it. Users outside the caller's data scope are not found
+//  @Tags           users
+//  @Produce        json
+//  @Param          id      path    string  true    "id"
+//  @Success        200     {array}     models.Permission
+//  @Failure        404     {object}    models.HTTPError    "User not found with Id"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /accounts/{id}/permissions   [get]
+func (t UserController) GetUserPermissions(c *gin.Context) {
+	id := c.Param("id")
+
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	if _, code, err := userService.GetUserByID(id); err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Failed to retrieve permissions: %v", err.Error()),
+		})
+		return
+	}
+
+	permissions, code, err := t.AccessService.GetUserPermissions(id)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Failed to retrieve permissions: %v", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, *permissions)
+}
+
+//  @Summary        Get roles of a User
+//  @Description    Retrieve every role assigned to a User. Users outside the caller's data scope are not found
+//  @Tags           users
+//  @Produce        json
+//  @Param          id      path    string  true    "id"
+//  @Success        200     {array}     models.Role
+//  @Failure        404     {object}    models.HTTPError    "User not found with Id"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /accounts/{id}/roles   [get]
+func (t UserController) GetUserRoles(c *gin.Context) {
+	id := c.Param("id")
+
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	roles, code, err := userService.GetUserRoles(id)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Failed to retrieve roles: %v", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, *roles)
+}
+
+//  @Summary        Assign a Role to a User
+//  @Description    Add a role to the roles held by a User, optionally only between validFrom and validUntil. Users outside the caller's data scope are not found
+//  @Tags           users
+//  @Produce        json
+//  @Param          id          path    string              true    "id"
+//  @Param          userRole    body    models.UserRole     true    "Role to assign"
+//  @Success        201     {object}    models.UserRole
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body"
+//  @Failure        403     {object}    models.HTTPError    "Role is ranked above the caller or is one of the caller's own roles, the caller may not write role, or the caller holds no ranked role"
+//  @Failure        404     {object}    models.HTTPError    "User or Role not found with Id"
+//  @Failure        409     {object}    models.HTTPError    "Role conflicts with a role the user holds"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /accounts/{id}/roles   [post]
+func (t UserController) AddUserRole(c *gin.Context) {
+	id := c.Param("id")
+	var userRole models.UserRole
+	if err := c.BindJSON(&userRole); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid JSON request: %v", err.Error()),
+		})
+		return
+	}
+	if err := validate.Struct(userRole); err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	if code, err := t.PrivilegeService.CheckUserChange(callerId(c), id, int(userRole.RoleId)); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to assign role.", err))
+		return
+	}
+	if code, err := t.FieldPolicyService.CheckRoleChange(callerId(c)); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to assign role.", err))
+		return
+	}
+
+	res, code, err := userService.WithContext(c.Request.Context()).AddUserRole(id, userRole)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to assign role. %v", err.Error()),
+		})
+		return
+	}
+
+	c.Set("userRole", *res)
+	c.JSON(code, *res)
+}
+
+//  @Summary        Revoke a Role from a User
+//  @Description    Remove a role from the roles held by a User. Users outside the caller's data scope are not found
+//  @Tags           users
+//  @Produce        json
+//  @Param          id          path    string  true    "id"
+//  @Param          roleId      path    int     true    "roleId"
+//  @Success        200     {object}    models.UserRole
+//  @Failure        400     {object}    models.HTTPError    "RoleId must be a number"
+//  @Failure        403     {object}    models.HTTPError    "Role is ranked above the caller or is one of the caller's own roles, the caller may not write role, or the caller holds no ranked role"
+//  @Failure        404     {object}    models.HTTPError    "User does not have the role"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /accounts/{id}/roles/{roleId}   [delete]
+func (t UserController) RemoveUserRole(c *gin.Context) {
+	id := c.Param("id")
+	roleId, err := strconv.ParseUint(c.Param("roleId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: "Role ID must be a number",
+		})
+		return
+	}
+
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	if code, err := t.PrivilegeService.CheckUserChange(callerId(c), id, int(roleId)); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to revoke role.", err))
+		return
+	}
+	if code, err := t.FieldPolicyService.CheckRoleChange(callerId(c)); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to revoke role.", err))
+		return
+	}
+
+	res, code, err := userService.WithContext(c.Request.Context()).RemoveUserRole(id, uint(roleId))
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to revoke role. %v", err.Error()),
+		})
+		return
+	}
+
+	c.Set("userRole", *res)
+	c.JSON(http.StatusOK, *res)
 }
 
 //  @Summary        Add a User
-//  @Description    Add a User into Database
+//  @Description    Add a User into Database. The email is trimmed, lower-cased and its domain converted to ASCII, and must be unique
 //  @Tags           users
 //  @Produce        json
 //  @Param          user    body        models.User     true    "User Details"
 //  @Success        200     {object}    models.User
 //  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body"
+//  @Failure        403     {object}    models.HTTPError    "Role is ranked above the caller, or the caller holds no ranked role"
+//  @Failure        409     {object}    models.HTTPError    "Email is already in use, by the user in existingId when the caller can see them and their email"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /accounts   [post]
 func (t UserController) AddUser(c *gin.Context) {
@@ -178,28 +626,53 @@ func (t UserController) AddUser(c *gin.Context) {
 		})
 		return
 	}
+	user.Stamps = models.Stamps{}
 
-	res, code, err := t.UserService.AddUser(&user)
+	if user.Role != nil {
+		if code, err := t.PrivilegeService.CheckRoleRanks(callerId(c), int(*user.Role)); err != nil {
+			c.JSON(code, privilegeError(code, "Unable to create user.", err))
+			return
+		}
+	}
+	redaction, ok := t.redaction(c)
+	if !ok {
+		return
+	}
+
+	// The 409 for a taken email only names users the caller can see, and only
+	// when they can see emails
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	res, code, err := userService.WithRedaction(redaction).WithContext(c.Request.Context()).AddUser(&user)
 	if err != nil {
 		c.JSON(code, models.HTTPError{
-			Code:    code,
-			Message: fmt.Sprintf("Unable to create user. %v", err.Error()),
+			Code:       code,
+			Message:    fmt.Sprintf("Unable to create user. %v", err.Error()),
+			ExistingId: services.ExistingUserId(err),
 		})
 		return
 	}
 
 	c.Set("user", *res)
-	c.JSON(code, *res)
+	c.Header("ETag", etag(res.Version))
+	c.JSON(code, redaction.User(*res))
 }
 
 //  @Summary        Update User Details by Id
-//  @Description    Update a User By UserID
+//  @Description    Update a User By UserID. Users outside the caller's data scope are not found. Fields the caller's field policies do not allow are rejected, or dropped and listed in X-Dropped-Fields when FIELD_POLICY_MODE is drop
 //  @Tags           users
 //  @Produce        json
 //  @Param          id      path    string  true    "id"
+//  @Param          If-Match    header  string  false   "ETag of the user the change is based on"
 //  @Success        200     {object}    models.User
-//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body"
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to invalid JSON body, or fields written with their masked value"
+//  @Failure        403     {object}    models.HTTPError    "User or role is ranked above the caller, the caller's own role, fields the caller may not write, or the caller holds no ranked role"
 //  @Failure        404     {object}    models.HTTPError    "User not found with Id"
+//  @Failure        409     {object}    models.HTTPError    "Role conflicts with a role the user holds, or email is already in use, by the user in existingId when the caller can see them and their email"
+//  @Failure        412     {object}    models.HTTPError    "If-Match does not match the current ETag"
+//  @Failure        428     {object}    models.HTTPError    "If-Match is required when REQUIRE_IF_MATCH is set"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /accounts/{id}   [put]
 func (t UserController) UpdateUserById(c *gin.Context) {
@@ -213,10 +686,16 @@ func (t UserController) UpdateUserById(c *gin.Context) {
 		})
 		return
 	}
+	user.Stamps = models.Stamps{}
 
 	c.Set("user", user)
 
-	res, code, err := t.UserService.UpdateUserById(&user, id)
+	// Users outside the caller's data scope are not found, as for GET
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	existingUser, code, err := userService.GetUserByID(id)
 	if err != nil {
 		c.JSON(code, models.HTTPError{
 			Code:    code,
@@ -224,6 +703,133 @@ func (t UserController) UpdateUserById(c *gin.Context) {
 		})
 		return
 	}
+	// A missing role keeps the current one
+	if user.Role == nil {
+		user.Role = existingUser.Role
+	}
+	redaction, ok := t.redaction(c)
+	if !ok {
+		return
+	}
+	t.writeUser(c, userService, redaction, existingUser, &user, false)
+}
+
+//  @Summary        Patch User Details by Id
+//  @Description    Partially update a User with an RFC 7396 merge patch (application/merge-patch+json or application/json), or an RFC 6902 JSON Patch (application/json-patch+json). The patched user is validated as a whole, and "role": null clears the primary role. Users outside the caller's data scope are not found
+//  @Tags           users
+//  @Accept         json
+//  @Produce        json
+//  @Param          id      path    string  true    "id"
+//  @Param          patch   body    object  true    "Merge patch or JSON Patch"
+//  @Param          If-Match    header  string  false   "ETag of the user the change is based on"
+//  @Success        200     {object}    models.User
+//  @Failure        400     {object}    models.HTTPError    "Bad request due to an invalid patch or patched user, or fields written with their masked value"
+//  @Failure        403     {object}    models.HTTPError    "User or role is ranked above the caller, the caller's own role, fields the caller may not write, a JSON Patch test, copy or move reading a field redacted for the caller, or the caller holds no ranked role"
+//  @Failure        404     {object}    models.HTTPError    "User not found with Id"
+//  @Failure        409     {object}    models.HTTPError    "A JSON Patch test failed, role conflicts with a role the user holds, or email is already in use, by the user in existingId when the caller can see them and their email"
+//  @Failure        415     {object}    models.HTTPError    "Unsupported patch media type"
+//  @Failure        422     {object}    models.HTTPError    "The patch cannot be applied, or yields unknown fields"
+//  @Failure        412     {object}    models.HTTPError    "If-Match does not match the current ETag"
+//  @Failure        428     {object}    models.HTTPError    "If-Match is required when REQUIRE_IF_MATCH is set"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /accounts/{id}   [patch]
+func (t UserController) PatchUserById(c *gin.Context) {
+	id := c.Param("id")
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid request: %v", err.Error()),
+		})
+		return
+	}
+
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	existingUser, code, err := userService.GetUserByID(id)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to update user. %v", err.Error()),
+		})
+		return
+	}
+	c.Set("user", *existingUser)
+	redaction, ok := t.redaction(c)
+	if !ok {
+		return
+	}
+
+	user, code, err := services.PatchUser(existingUser, patch, c.ContentType(), redaction)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to patch user. %v", err.Error()),
+		})
+		return
+	}
+	t.writeUser(c, userService, redaction, existingUser, user, true)
+}
+
+// writeUser checks the caller's field policies and privileges for changing
+// existingUser into user, then writes it through the caller's scoped
+// userService and responds with the caller's redaction applied. It is shared
+// by every path that updates users. replace writes a nil role as null.
+func (t UserController) writeUser(c *gin.Context, userService *services.UserService, redaction services.Redaction, existingUser, user *models.User, replace bool) {
+	id := existingUser.Id
+	if !checkIfMatch(c, existingUser.Version) {
+		return
+	}
+	// Without a version in the body, the write is against the version read here
+	if user.Version == 0 {
+		user.Version = existingUser.Version
+	}
+
+	if masked := redaction.MaskedFields(existingUser, user); len(masked) > 0 {
+		c.JSON(http.StatusBadRequest, models.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Unable to update user. %s cannot be written with the value shown to you", strings.Join(masked, ", ")),
+		})
+		return
+	}
+
+	dropped, code, err := t.FieldPolicyService.CheckUserUpdate(callerId(c), existingUser, user)
+	if err != nil {
+		c.JSON(code, privilegeError(code, "Unable to update user.", err))
+		return
+	}
+	if len(dropped) > 0 {
+		c.Header("X-Dropped-Fields", strings.Join(dropped, ","))
+	}
+
+	var roleIds []int
+	if user.Role != nil && (existingUser.Role == nil || *existingUser.Role != *user.Role) {
+		roleIds = append(roleIds, int(*user.Role))
+	} else if user.Role == nil && existingUser.Role != nil {
+		roleIds = append(roleIds, int(*existingUser.Role))
+	}
+	if code, err := t.PrivilegeService.CheckUserChange(callerId(c), id, roleIds...); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to update user.", err))
+		return
+	}
+
+	var res *models.User
+	userService = userService.WithRedaction(redaction)
+	if replace {
+		res, code, err = userService.WithContext(c.Request.Context()).ReplaceUserById(user, id)
+	} else {
+		res, code, err = userService.WithContext(c.Request.Context()).UpdateUserById(user, id)
+	}
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:       code,
+			Message:    fmt.Sprintf("Unable to update user. %v", err.Error()),
+			ExistingId: services.ExistingUserId(err),
+		})
+		return
+	}
 
 	// deletedCount, cacheErr := cache.RedisClient.Del(context.Background(), id).Result()
 	// if cacheErr != nil {
@@ -234,17 +840,22 @@ func (t UserController) UpdateUserById(c *gin.Context) {
 
 	// Return the updated user
 	c.Set("updatedUser", *res)
-	c.JSON(code, *res)
+	c.Header("ETag", etag(res.Version))
+	c.JSON(code, redaction.User(*res))
 }
 
 //  @Summary        Delete a User by Id
-//  @Description    Delete a User By UserID
+//  @Description    Soft-delete a User By UserID. Users outside the caller's data scope are not found. It is hidden from every query and its roles grant nothing until restored, and it is purged after USER_RETENTION_DAYS. Its email stays taken until then
 //  @Tags           users
 //  @Produce        json
 //  @Param          id      path    string  true    "id"
+//  @Param          If-Match    header  string  false   "ETag of the user the change is based on"
 //  @Success        200     "Success"   
 //  @Failure        400     {object}    models.HTTPError    "Bad request due to empty string Id"
+//  @Failure        403     {object}    models.HTTPError    "User is ranked above the caller or is the caller's own account, or the caller holds no ranked role"
 //  @Failure        404     {object}    models.HTTPError    "User not found with Id"
+//  @Failure        412     {object}    models.HTTPError    "If-Match does not match the current ETag"
+//  @Failure        428     {object}    models.HTTPError    "If-Match is required when REQUIRE_IF_MATCH is set"
 //  @Failure        500     {object}    models.HTTPError
 //  @Router         /accounts/{id}   [delete]
 func (t UserController) DeleteUserById(c *gin.Context) {
@@ -273,11 +884,33 @@ func (t UserController) DeleteUserById(c *gin.Context) {
 		c.JSON(http.StatusForbidden, models.HTTPError{
 			Code:    http.StatusForbidden,
 			Message: "Cannot delete own account",
+			Reason:  services.ReasonSelfDelete,
+		})
+		return
+	}
+
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	existingUser, code, err := userService.GetUserByID(id)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to delete user. %v", err.Error()),
 		})
 		return
 	}
+	if !checkIfMatch(c, existingUser.Version) {
+		return
+	}
+
+	if code, err := t.PrivilegeService.CheckUserChange(callerId(c), id); err != nil {
+		c.JSON(code, privilegeError(code, "Unable to delete user.", err))
+		return
+	}
 
-	res, code, err := t.UserService.DeleteUserById(id)
+	res, code, err := userService.WithContext(c.Request.Context()).DeleteUserById(id)
 	if err != nil {
 		c.JSON(code, models.HTTPError{
 			Code:    code,
@@ -299,13 +932,75 @@ func (t UserController) DeleteUserById(c *gin.Context) {
 	})
 }
 
+//  @Summary        Restore a deleted User by Id
+//  @Description    Bring back a soft-deleted User with the roles it held. Its primary role is assigned again if the role still exists. Users outside the caller's data scope are not found
+//  @Tags           users
+//  @Produce        json
+//  @Param          id      path    string  true    "id"
+//  @Param          If-Match    header  string  false   "ETag of the deleted user"
+//  @Success        200     {object}    models.User
+//  @Header         200     {string}    ETag    "Version of the user"
+//  @Failure        403     {object}    models.HTTPError    "User's role is ranked above the caller, or the caller holds no ranked role"
+//  @Failure        404     {object}    models.HTTPError    "User not found with Id"
+//  @Failure        409     {object}    models.HTTPError    "User is not deleted, or holds roles that now conflict"
+//  @Failure        412     {object}    models.HTTPError    "If-Match does not match the current ETag"
+//  @Failure        428     {object}    models.HTTPError    "If-Match is required when REQUIRE_IF_MATCH is set"
+//  @Failure        500     {object}    models.HTTPError
+//  @Router         /accounts/{id}/restore   [post]
+func (t UserController) RestoreUserById(c *gin.Context) {
+	id := c.Param("id")
+
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	userService = userService.WithDeleted()
+	existingUser, code, err := userService.GetUserByID(id)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to restore user. %v", err.Error()),
+		})
+		return
+	}
+	c.Set("user", *existingUser)
+	if !checkIfMatch(c, existingUser.Version) {
+		return
+	}
+
+	// Restoring hands the primary role back, so the caller must outrank it
+	if existingUser.Role != nil {
+		if code, err := t.PrivilegeService.CheckRoleRanks(callerId(c), int(*existingUser.Role)); err != nil {
+			c.JSON(code, privilegeError(code, "Unable to restore user.", err))
+			return
+		}
+	}
+
+	res, code, err := userService.WithContext(c.Request.Context()).RestoreUserById(id)
+	if err != nil {
+		c.JSON(code, models.HTTPError{
+			Code:    code,
+			Message: fmt.Sprintf("Unable to restore user. %v", err.Error()),
+		})
+		return
+	}
+	redaction, ok := t.redaction(c)
+	if !ok {
+		return
+	}
+
+	c.Set("updatedUser", *res)
+	c.Header("ETag", etag(res.Version))
+	c.JSON(http.StatusOK, redaction.User(*res))
+}
+
 
 type Input struct {
     Roles []int `json:"roles" validate:"required"`
 }
 
 //  @Summary        Get a list of users with roles
-//  @Description    Get a list of users with roles
+//  @Description    Get a list of users with roles within the caller's data scope
 //  @Tags           users
 //  @Produce        json
 //  @Param          user    body    Input    true    "roles"
@@ -329,8 +1024,17 @@ func (t UserController) GetUsersWithRole(c *gin.Context) {
         return
 	}
 
+	userService, ok := t.scopedUserService(c)
+	if !ok {
+		return
+	}
+	redaction, ok := t.redaction(c)
+	if !ok {
+		return
+	}
+
 	roles := input.Roles
-	res, code, err := t.UserService.GetUsersWithRole(roles)
+	res, code, err := userService.GetUsersWithRole(roles)
 	if err != nil {
 		c.JSON(code, models.HTTPError{
 			Code:    code,
@@ -338,5 +1042,5 @@ func (t UserController) GetUsersWithRole(c *gin.Context) {
 		})
 		return
 	}
-	c.JSON(http.StatusOK, *res)
+	c.JSON(http.StatusOK, redaction.Users(*res))
 }
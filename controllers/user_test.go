@@ -0,0 +1,265 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("Error creating mock DB: %v", err)
+    }
+    gormDB, err := gorm.Open(mysql.New(mysql.Config{
+        Conn:                      db,
+        DriverName:                "mysql",
+        SkipInitializeWithVersion: true,
+    }), &gorm.Config{SkipDefaultTransaction: true})
+    if err != nil {
+        t.Fatalf("Error creating GORM DB: %v", err)
+    }
+    return gormDB, mock
+}
+
+// performRequest serves a single request through handler, registered on
+// route, as the given caller.
+func performRequest(route, method, path string, callerId string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+    gin.SetMode(gin.TestMode)
+    router := gin.New()
+    router.Handle(method, route, func(c *gin.Context) {
+        c.Set("userDetails", map[string]interface{}{"user_id": callerId})
+    }, handler)
+
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+    return w
+}
+
+const (
+    userExistsQuery = "SELECT `id` FROM `users` WHERE id = ? AND `users`.`deleted_at` IS NULL"
+    userRolesQuery  = "SELECT `role_id` FROM `user_roles` WHERE user_id = ?"
+    userByIdQuery   = "SELECT * FROM `users` WHERE id = ? AND `users`.`deleted_at` IS NULL"
+)
+
+// expectUnscoped makes callerId hold no role, so they see every user.
+func expectUnscoped(mock sqlmock.Sqlmock, callerId string) {
+    mock.ExpectQuery(regexp.QuoteMeta(userRolesQuery)).
+        WithArgs(callerId, sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}))
+}
+
+// expectScope makes callerId hold roleId, whose holders only see users with
+// targetRoleId.
+func expectScope(mock sqlmock.Sqlmock, callerId string, roleId, targetRoleId int) {
+    mock.ExpectQuery(regexp.QuoteMeta(userRolesQuery)).
+        WithArgs(callerId, sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(roleId))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `data_scopes` WHERE role_id IN (?)")).
+        WithArgs(roleId).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id", "target_role_id"}).AddRow(roleId, targetRoleId))
+}
+
+func TestGetUserPermissions(t *testing.T) {
+    db, mock := newMockDB(t)
+    user := NewUserController(*db)
+
+    expectUnscoped(mock, "9")
+    mock.ExpectQuery(regexp.QuoteMeta(userByIdQuery)).
+        WithArgs("1").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+    mock.ExpectQuery(regexp.QuoteMeta(userExistsQuery)).
+        WithArgs("1").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+    mock.ExpectQuery(regexp.QuoteMeta(userRolesQuery)).
+        WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(2))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `roles` WHERE id = ?")).
+        WithArgs(2).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(2, "Manager", nil))
+    mock.ExpectQuery(regexp.QuoteMeta("FROM `access_points` JOIN role_access ON role_access.ap_id = access_points.id")).
+        WithArgs(2).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "method", "endpoint", "role_id", "role_name"}).
+            AddRow(1, "List users", "GET", "/users/accounts", 2, "Manager"))
+
+    w := performRequest("/accounts/:id/permissions", http.MethodGet, "/accounts/1/permissions", "9", user.GetUserPermissions)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    var permissions []models.Permission
+    assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &permissions))
+    assert.Len(t, permissions, 1)
+    assert.Equal(t, "/users/accounts", permissions[0].AccessPoint.EndPoint)
+    assert.Equal(t, 2, permissions[0].Grant.RoleId)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserPermissions_UnknownUser(t *testing.T) {
+    db, mock := newMockDB(t)
+    user := NewUserController(*db)
+
+    expectUnscoped(mock, "9")
+    mock.ExpectQuery(regexp.QuoteMeta(userByIdQuery)).
+        WithArgs("404").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+    w := performRequest("/accounts/:id/permissions", http.MethodGet, "/accounts/404/permissions", "9", user.GetUserPermissions)
+
+    assert.Equal(t, http.StatusNotFound, w.Code)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserPermissions_Empty(t *testing.T) {
+    db, mock := newMockDB(t)
+    user := NewUserController(*db)
+
+    expectUnscoped(mock, "9")
+    mock.ExpectQuery(regexp.QuoteMeta(userByIdQuery)).
+        WithArgs("2").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("2"))
+    mock.ExpectQuery(regexp.QuoteMeta(userExistsQuery)).
+        WithArgs("2").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("2"))
+    mock.ExpectQuery(regexp.QuoteMeta(userRolesQuery)).
+        WithArgs("2", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}))
+
+    w := performRequest("/accounts/:id/permissions", http.MethodGet, "/accounts/2/permissions", "9", user.GetUserPermissions)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.JSONEq(t, "[]", w.Body.String())
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReadUserAccess_OutOfScope(t *testing.T) {
+    for _, route := range []string{"/accounts/:id/permissions", "/accounts/:id/roles"} {
+        t.Run(route, func(t *testing.T) {
+            db, mock := newMockDB(t)
+            user := NewUserController(*db)
+            handlers := map[string]gin.HandlerFunc{
+                "/accounts/:id/permissions": user.GetUserPermissions,
+                "/accounts/:id/roles":       user.GetUserRoles,
+            }
+
+            // The caller only sees users holding role 2, which user 7 does not
+            expectScope(mock, "9", 5, 2)
+            mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE id IN (SELECT user_roles.user_id FROM `user_roles` WHERE user_roles.role_id IN (?)")).
+                WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+            w := performRequest(route, http.MethodGet, strings.Replace(route, ":id", "7", 1), "9", handlers[route])
+
+            assert.Equal(t, http.StatusNotFound, w.Code)
+            assert.NoError(t, mock.ExpectationsWereMet())
+        })
+    }
+}
+
+func TestWriteUser_OutOfScope(t *testing.T) {
+    tests := []struct {
+        method string
+        route  string
+        body   string
+    }{
+        {http.MethodPut, "/accounts/:id", `{"firstName":"Jane","lastName":"Doe","email":"jane@example.com"}`},
+        {http.MethodPatch, "/accounts/:id", `{"firstName":"Jane"}`},
+        {http.MethodDelete, "/accounts/:id", ""},
+    }
+
+    for _, tc := range tests {
+        t.Run(tc.method, func(t *testing.T) {
+            db, mock := newMockDB(t)
+            user := NewUserController(*db)
+            handlers := map[string]gin.HandlerFunc{
+                http.MethodPut:    user.UpdateUserById,
+                http.MethodPatch:  user.PatchUserById,
+                http.MethodDelete: user.DeleteUserById,
+            }
+
+            // The caller only sees users holding role 2, which user 7 does not
+            expectScope(mock, "9", 5, 2)
+            mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE id IN (SELECT user_roles.user_id FROM `user_roles` WHERE user_roles.role_id IN (?)")).
+                WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+            gin.SetMode(gin.TestMode)
+            router := gin.New()
+            router.Handle(tc.method, tc.route, func(c *gin.Context) {
+                c.Set("userDetails", map[string]interface{}{"user_id": "9"})
+            }, handlers[tc.method])
+            w := httptest.NewRecorder()
+            router.ServeHTTP(w, httptest.NewRequest(tc.method, "/accounts/7", strings.NewReader(tc.body)))
+
+            assert.Equal(t, http.StatusNotFound, w.Code)
+            assert.NoError(t, mock.ExpectationsWereMet())
+        })
+    }
+}
+
+func TestAddUserRole_StaleIfMatch(t *testing.T) {
+    db, mock := newMockDB(t)
+    user := NewUserController(*db)
+    userColumns := []string{"id", "first_name", "last_name", "email", "role", "version"}
+
+    // Super Admin 9 assigns role 4 to user 1, whose first permanent role it becomes
+    expectUnscoped(mock, "9")
+    mock.ExpectQuery(regexp.QuoteMeta(userRolesQuery)).
+        WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}))
+    mock.ExpectQuery(regexp.QuoteMeta("FROM `roles` JOIN user_roles ON user_roles.role_id = roles.id")).
+        WithArgs("9", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role_rank"}).AddRow(10, "Super Admin", 1000))
+    expectUnscoped(mock, "9")
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `roles` WHERE id = ?")).
+        WithArgs(4).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(4, "Sales"))
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta(userByIdQuery)).
+        WithArgs("1").
+        WillReturnRows(sqlmock.NewRows(userColumns).AddRow("1", "Jane", "Doe", "jane@example.com", nil, 1))
+    mock.ExpectQuery(regexp.QuoteMeta(userRolesQuery)).
+        WithArgs("1", sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}))
+    mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user_roles`")).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    // Setting the primary role moves the user on to a new version
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `role`=?,`version`=version + 1,`updated_at`=? WHERE `users`.`deleted_at` IS NULL AND `id` = ?")).
+        WithArgs(4, sqlmock.AnyArg(), "1").
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
+
+    gin.SetMode(gin.TestMode)
+    router := gin.New()
+    router.Use(func(c *gin.Context) {
+        c.Set("userDetails", map[string]interface{}{"user_id": "9"})
+    })
+    router.POST("/accounts/:id/roles", user.AddUserRole)
+    router.PATCH("/accounts/:id", user.PatchUserById)
+
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/accounts/1/roles", strings.NewReader(`{"roleId":4}`)))
+    assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+    // A patch made against the ETag read before the assignment would revert it
+    expectUnscoped(mock, "9")
+    mock.ExpectQuery(regexp.QuoteMeta(userByIdQuery)).
+        WithArgs("1").
+        WillReturnRows(sqlmock.NewRows(userColumns).AddRow("1", "Jane", "Doe", "jane@example.com", 4, 2))
+    expectUnscoped(mock, "9")
+
+    request := httptest.NewRequest(http.MethodPatch, "/accounts/1", strings.NewReader(`{"role":null}`))
+    request.Header.Set("Content-Type", "application/merge-patch+json")
+    request.Header.Set("If-Match", `"1"`)
+    w = httptest.NewRecorder()
+    router.ServeHTTP(w, request)
+
+    assert.Equal(t, http.StatusPreconditionFailed, w.Code, w.Body.String())
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
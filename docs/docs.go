@@ -15,9 +15,66 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/access-points/match": {
+            "get": {
+                "description": "Retrieves every access point whose method and path pattern apply to a request with the given method and concrete path",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "access-points"
+                ],
+                "summary": "Match Access Points",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "GET",
+                        "description": "HTTP method of the request",
+                        "name": "method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Concrete request path, such as /users/accounts/42",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AccessPoint"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Path cannot be empty",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller is not an administrator",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
         "/accounts": {
             "get": {
-                "description": "Retrieves a list of users",
+                "description": "Retrieves a list of users within the caller's data scope, with PII shaped by the caller's PII policies",
                 "produces": [
                     "application/json"
                 ],
@@ -25,6 +82,86 @@ const docTemplate = `{
                     "users"
                 ],
                 "summary": "Get all Users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only ids starting with this",
+                        "name": "id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only users holding this role, or 0 for users without a role",
+                        "name": "role",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only first or last names starting with this",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only emails starting with this",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "SCIM filter over id, firstName, lastName, email, role, createdAt, updatedAt, createdBy and updatedBy, such as role eq 2 and (email ew \\",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted users (admins only)",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows created by this user id",
+                        "name": "createdBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows last changed by this user id",
+                        "name": "updatedBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows created at or after this RFC 3339 time",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows created before this RFC 3339 time",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows changed at or after this RFC 3339 time",
+                        "name": "updatedAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows changed before this RFC 3339 time",
+                        "name": "updatedBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated id, firstName, lastName, email, createdAt, updatedAt, createdBy or updatedBy, each prefixed with - to descend",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -35,6 +172,18 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "400": {
+                        "description": "Invalid parameters or filter",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "includeDeleted requested by a non-admin, or a filter or sort on a field redacted for the caller",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -44,7 +193,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Add a User into Database",
+                "description": "Add a User into Database. The email is trimmed, lower-cased and its domain converted to ASCII, and must be unique",
                 "produces": [
                     "application/json"
                 ],
@@ -76,6 +225,77 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.HTTPError"
                         }
                     },
+                    "403": {
+                        "description": "Role is ranked above the caller, or the caller holds no ranked role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Email is already in use, by the user in existingId when the caller can see them and their email",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/accounts/by-email/{email}": {
+            "get": {
+                "description": "Retrieve the User with exactly this email, once normalized. Users outside the caller's data scope are not found",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get User by email",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "email",
+                        "name": "email",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the user"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Email is malformed",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Email is redacted for the caller",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found with email",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -87,7 +307,7 @@ const docTemplate = `{
         },
         "/accounts/paginate": {
             "get": {
-                "description": "Retrieves a list of users",
+                "description": "Retrieves a page of users within the caller's data scope in a stable order. Pass back pagination.next or pagination.prev as cursor, with the same sort, to move between pages",
                 "produces": [
                     "application/json"
                 ],
@@ -98,17 +318,154 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "page",
-                        "name": "page",
-                        "in": "query",
-                        "required": true
+                        "default": 10,
+                        "description": "Page size, up to PAGE_SIZE_MAX",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Token from a previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count every matching user",
+                        "name": "total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only ids starting with this",
+                        "name": "id",
+                        "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "size",
-                        "name": "size",
+                        "description": "Only users holding this role, or 0 for users without a role",
+                        "name": "role",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only first or last names starting with this",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only emails starting with this",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "SCIM filter over id, firstName, lastName, email, role, createdAt, updatedAt, createdBy and updatedBy, such as role eq 2 and (email ew \\",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted users (admins only)",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows created by this user id",
+                        "name": "createdBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows last changed by this user id",
+                        "name": "updatedBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows created at or after this RFC 3339 time",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows created before this RFC 3339 time",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows changed at or after this RFC 3339 time",
+                        "name": "updatedAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows changed before this RFC 3339 time",
+                        "name": "updatedBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated id, firstName, lastName, email, createdAt, updatedAt, createdBy or updatedBy, each prefixed with - to descend",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters, filter or cursor",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "includeDeleted requested by a non-admin, or a filter or sort on a field redacted for the caller",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/accounts/search": {
+            "get": {
+                "description": "Find users within the caller's data scope by name or email, best matches first. Every word of q has to match, ignoring case and accents, and q needs a word of at least 3 letters or digits. Fields the caller's PII policies hide are not searched",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Search Users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Words to search for",
+                        "name": "q",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Most results to return, up to PAGE_SIZE_MAX",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -117,12 +474,12 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.User"
+                                "$ref": "#/definitions/models.UserSearchResult"
                             }
                         }
                     },
                     "400": {
-                        "description": "Invalid parameters",
+                        "description": "Query without a word of at least 3 letters or digits, or invalid limit",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
@@ -138,7 +495,7 @@ const docTemplate = `{
         },
         "/accounts/with-roles": {
             "post": {
-                "description": "Get a list of users with roles",
+                "description": "Get a list of users with roles within the caller's data scope",
                 "produces": [
                     "application/json"
                 ],
@@ -184,7 +541,7 @@ const docTemplate = `{
         },
         "/accounts/{id}": {
             "get": {
-                "description": "Retrieve a User By UserID",
+                "description": "Retrieve a User By UserID. Users outside the caller's data scope are not found",
                 "produces": [
                     "application/json"
                 ],
@@ -199,6 +556,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also find a soft-deleted user (admins only)",
+                        "name": "includeDeleted",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -209,6 +572,12 @@ const docTemplate = `{
                             "items": {
                                 "$ref": "#/definitions/models.User"
                             }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the user"
+                            }
                         }
                     },
                     "400": {
@@ -217,6 +586,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.HTTPError"
                         }
                     },
+                    "403": {
+                        "description": "includeDeleted requested by a non-admin",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
                     "404": {
                         "description": "User not found with Id",
                         "schema": {
@@ -232,7 +607,7 @@ const docTemplate = `{
                 }
             },
             "put": {
-                "description": "Update a User By UserID",
+                "description": "Update a User By UserID. Users outside the caller's data scope are not found. Fields the caller's field policies do not allow are rejected, or dropped and listed in X-Dropped-Fields when FIELD_POLICY_MODE is drop",
                 "produces": [
                     "application/json"
                 ],
@@ -247,6 +622,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the user the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -257,7 +638,13 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Bad request due to invalid JSON body",
+                        "description": "Bad request due to invalid JSON body, or fields written with their masked value",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "User or role is ranked above the caller, the caller's own role, fields the caller may not write, or the caller holds no ranked role",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
@@ -268,17 +655,35 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.HTTPError"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "409": {
+                        "description": "Role conflicts with a role the user holds, or email is already in use, by the user in existingId when the caller can see them and their email",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
-                    }
-                }
-            },
-            "delete": {
-                "description": "Delete a User By UserID",
-                "produces": [
+                    },
+                    "412": {
+                        "description": "If-Match does not match the current ETag",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required when REQUIRE_IF_MATCH is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Soft-delete a User By UserID. Users outside the caller's data scope are not found. It is hidden from every query and its roles grant nothing until restored, and it is purged after USER_RETENTION_DAYS. Its email stays taken until then",
+                "produces": [
                     "application/json"
                 ],
                 "tags": [
@@ -292,6 +697,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the user the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -304,56 +715,28 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.HTTPError"
                         }
                     },
+                    "403": {
+                        "description": "User is ranked above the caller or is the caller's own account, or the caller holds no ranked role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
                     "404": {
                         "description": "User not found with Id",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "412": {
+                        "description": "If-Match does not match the current ETag",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
-                    }
-                }
-            }
-        },
-        "/health": {
-            "get": {
-                "description": "Check the health of the service",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "health"
-                ],
-                "summary": "Get Health",
-                "responses": {
-                    "200": {
-                        "description": "Sucess"
-                    }
-                }
-            }
-        },
-        "/role-access": {
-            "get": {
-                "description": "Retrieves a list of Role Access",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "role-access"
-                ],
-                "summary": "Get all Role Accesses",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    },
+                    "428": {
+                        "description": "If-Match is required when REQUIRE_IF_MATCH is set",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.RoleAccess"
-                            }
+                            "$ref": "#/definitions/models.HTTPError"
                         }
                     },
                     "500": {
@@ -364,88 +747,93 @@ const docTemplate = `{
                     }
                 }
             },
-            "post": {
-                "description": "Add a Role Access into Database",
+            "patch": {
+                "description": "Partially update a User with an RFC 7396 merge patch (application/merge-patch+json or application/json), or an RFC 6902 JSON Patch (application/json-patch+json). The patched user is validated as a whole, and \"role\": null clears the primary role. Users outside the caller's data scope are not found",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "role-access"
+                    "users"
                 ],
-                "summary": "Add a Role Access",
+                "summary": "Patch User Details by Id",
                 "parameters": [
                     {
-                        "description": "Role Access Details",
-                        "name": "role-access",
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Merge patch or JSON Patch",
+                        "name": "patch",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.RoleAccess"
+                            "type": "object"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the user the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.RoleAccess"
+                            "$ref": "#/definitions/models.User"
                         }
                     },
                     "400": {
-                        "description": "Bad request due to invalid JSON body",
+                        "description": "Bad request due to an invalid patch or patched user, or fields written with their masked value",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "403": {
+                        "description": "User or role is ranked above the caller, the caller's own role, fields the caller may not write, a JSON Patch test, copy or move reading a field redacted for the caller, or the caller holds no ranked role",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
-                    }
-                }
-            },
-            "delete": {
-                "description": "Delete a Role Access",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "role-access"
-                ],
-                "summary": "Delete a Role Access",
-                "parameters": [
-                    {
-                        "description": "Role ID",
-                        "name": "role_id",
-                        "in": "body",
-                        "required": true,
+                    },
+                    "404": {
+                        "description": "User not found with Id",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.HTTPError"
                         }
                     },
-                    {
-                        "description": "Access Point ID",
-                        "name": "ap_id",
-                        "in": "body",
-                        "required": true,
+                    "409": {
+                        "description": "A JSON Patch test failed, role conflicts with a role the user holds, or email is already in use, by the user in existingId when the caller can see them and their email",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.HTTPError"
                         }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "Success"
                     },
-                    "400": {
-                        "description": "Bad request due to invalid JSON object",
+                    "412": {
+                        "description": "If-Match does not match the current ETag",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
                     },
-                    "404": {
-                        "description": "Role access is not found given role_id and ap_id",
+                    "415": {
+                        "description": "Unsupported patch media type",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "The patch cannot be applied, or yields unknown fields",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required when REQUIRE_IF_MATCH is set",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
@@ -459,26 +847,41 @@ const docTemplate = `{
                 }
             }
         },
-        "/roles": {
+        "/accounts/{id}/permissions": {
             "get": {
-                "description": "Retrieves a list of Roles",
+                "description": "Retrieve every access point the User's role is granted, with the grant that produced it. Users outside the caller's data scope are not found",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "roles"
+                    "users"
+                ],
+                "summary": "Get effective permissions of a User",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get all Roles",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Role"
+                                "$ref": "#/definitions/models.Permission"
                             }
                         }
                     },
+                    "404": {
+                        "description": "User not found with Id",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -486,36 +889,72 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/accounts/{id}/restore": {
             "post": {
-                "description": "Add a Role into Database",
+                "description": "Bring back a soft-deleted User with the roles it held. Its primary role is assigned again if the role still exists. Users outside the caller's data scope are not found",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "roles"
+                    "users"
                 ],
-                "summary": "Add a Role",
+                "summary": "Restore a deleted User by Id",
                 "parameters": [
                     {
-                        "description": "Role Details",
-                        "name": "role",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.Role"
-                        }
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the deleted user",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Role"
+                            "$ref": "#/definitions/models.User"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the user"
+                            }
                         }
                     },
-                    "400": {
-                        "description": "Bad request due to invalid JSON body",
+                    "403": {
+                        "description": "User's role is ranked above the caller, or the caller holds no ranked role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found with Id",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "User is not deleted, or holds roles that now conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "412": {
+                        "description": "If-Match does not match the current ETag",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required when REQUIRE_IF_MATCH is set",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
@@ -529,16 +968,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/roles/{id}": {
+        "/accounts/{id}/roles": {
             "get": {
-                "description": "Retrieve a Role By RoleID",
+                "description": "Retrieve every role assigned to a User. Users outside the caller's data scope are not found",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "roles"
+                    "users"
                 ],
-                "summary": "Get Role by Id",
+                "summary": "Get roles of a User",
                 "parameters": [
                     {
                         "type": "string",
@@ -552,17 +991,14 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Role"
-                        }
-                    },
-                    "400": {
-                        "description": "RoleId cannot be empy",
-                        "schema": {
-                            "$ref": "#/definitions/models.HTTPError"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Role"
+                            }
                         }
                     },
                     "404": {
-                        "description": "Role not found with Id",
+                        "description": "User not found with Id",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
@@ -575,15 +1011,15 @@ const docTemplate = `{
                     }
                 }
             },
-            "put": {
-                "description": "Update a Role By RoleId",
+            "post": {
+                "description": "Add a role to the roles held by a User, optionally only between validFrom and validUntil. Users outside the caller's data scope are not found",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "roles"
+                    "users"
                 ],
-                "summary": "Update Role Details by Id",
+                "summary": "Assign a Role to a User",
                 "parameters": [
                     {
                         "type": "string",
@@ -591,13 +1027,22 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Role to assign",
+                        "name": "userRole",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UserRole"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.Role"
+                            "$ref": "#/definitions/models.UserRole"
                         }
                     },
                     "400": {
@@ -606,8 +1051,20 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.HTTPError"
                         }
                     },
+                    "403": {
+                        "description": "Role is ranked above the caller or is one of the caller's own roles, the caller may not write role, or the caller holds no ranked role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
                     "404": {
-                        "description": "Role not found with Id",
+                        "description": "User or Role not found with Id",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Role conflicts with a role the user holds",
                         "schema": {
                             "$ref": "#/definitions/models.HTTPError"
                         }
@@ -619,16 +1076,18 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/accounts/{id}/roles/{roleId}": {
             "delete": {
-                "description": "Delete a Role By RoleID",
+                "description": "Remove a role from the roles held by a User. Users outside the caller's data scope are not found",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "roles"
+                    "users"
                 ],
-                "summary": "Delete a Role by Id",
+                "summary": "Revoke a Role from a User",
                 "parameters": [
                     {
                         "type": "string",
@@ -636,9 +1095,1145 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
-                    }
-                ],
-                "responses": {
+                    },
+                    {
+                        "type": "integer",
+                        "description": "roleId",
+                        "name": "roleId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserRole"
+                        }
+                    },
+                    "400": {
+                        "description": "RoleId must be a number",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Role is ranked above the caller or is one of the caller's own roles, the caller may not write role, or the caller holds no ranked role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "User does not have the role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/authorize": {
+            "post": {
+                "description": "Evaluate one or more method and path checks for a user id or raw ID token against role_access and the loaded policies. Unknown users are denied. Only admins may ask about a subject other than themselves",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "authorization"
+                ],
+                "summary": "Decide whether a subject may call endpoints",
+                "parameters": [
+                    {
+                        "description": "Subject and checks",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AuthorizationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AuthorizationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON body",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "401": {
+                        "description": "Subject token is invalid",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Subject is someone else and the caller is not an admin",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/data-scopes": {
+            "get": {
+                "description": "Retrieves the rules limiting which users each role can see",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "data-scopes"
+                ],
+                "summary": "Get all Data Scopes",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.DataScope"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Let holders of roleId see users holding targetRoleId, or users without a role when targetRoleId is 0. Once a role has a data scope its holders only see users matching one of its scopes",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "data-scopes"
+                ],
+                "summary": "Add a Data Scope",
+                "parameters": [
+                    {
+                        "description": "Data Scope Details",
+                        "name": "data-scope",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DataScope"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.DataScope"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON body or unknown role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Data scope already exists",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a Data Scope",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "data-scopes"
+                ],
+                "summary": "Delete a Data Scope",
+                "parameters": [
+                    {
+                        "description": "Data Scope Details",
+                        "name": "data-scope",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DataScope"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.DataScope"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON body",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Data scope is not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/field-policies": {
+            "get": {
+                "description": "Retrieves the user fields each role may write",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "field-policies"
+                ],
+                "summary": "Get all Field Policies",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.FieldPolicy"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Let holders of roleId write field (firstName, lastName, email or role) of users. Once a role has a field policy its holders may only write the fields it lists",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "field-policies"
+                ],
+                "summary": "Add a Field Policy",
+                "parameters": [
+                    {
+                        "description": "Field Policy Details",
+                        "name": "field-policy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.FieldPolicy"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.FieldPolicy"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON body, unknown field or unknown role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Field policy already exists",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a Field Policy",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "field-policies"
+                ],
+                "summary": "Delete a Field Policy",
+                "parameters": [
+                    {
+                        "description": "Field Policy Details",
+                        "name": "field-policy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.FieldPolicy"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.FieldPolicy"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON body",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Field policy is not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Check the health of the service",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Get Health",
+                "responses": {
+                    "200": {
+                        "description": "Sucess"
+                    }
+                }
+            }
+        },
+        "/jobs/{name}": {
+            "post": {
+                "description": "Run a maintenance job once, for deployments that schedule jobs with cron or similar rather than EventBridge. sweep-role-assignments removes expired role assignments, and purge-deleted-users permanently deletes users soft-deleted more than USER_RETENTION_DAYS ago, and reload-policies reloads POLICY_DIR if it changed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Run a maintenance job",
+                "parameters": [
+                    {
+                        "enum": [
+                            "sweep-role-assignments",
+                            "purge-deleted-users",
+                            "reload-policies"
+                        ],
+                        "type": "string",
+                        "description": "Job name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.JobResult"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller is not an administrator",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/pii-policies": {
+            "get": {
+                "description": "Retrieves how each role sees the personal fields of users",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pii-policies"
+                ],
+                "summary": "Get all PII Policies",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.PIIPolicy"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Mask or omit field (firstName, lastName or email) of the users returned to holders of roleId. A field is shown in full if any role the caller holds has no policy for it. Adding a policy for an existing role and field changes its action",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pii-policies"
+                ],
+                "summary": "Add a PII Policy",
+                "parameters": [
+                    {
+                        "description": "PII Policy Details",
+                        "name": "pii-policy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PIIPolicy"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.PIIPolicy"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON body, unknown field or action, or unknown role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "PII policy already exists",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a PII Policy",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pii-policies"
+                ],
+                "summary": "Delete a PII Policy",
+                "parameters": [
+                    {
+                        "description": "PII Policy Details",
+                        "name": "pii-policy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PIIPolicy"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PIIPolicy"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON body",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "PII policy is not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/role-access": {
+            "get": {
+                "description": "Retrieves a list of Role Access",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "role-access"
+                ],
+                "summary": "Get all Role Accesses",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only rows created by this user id",
+                        "name": "createdBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows last changed by this user id",
+                        "name": "updatedBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows created at or after this RFC 3339 time",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows created before this RFC 3339 time",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows changed at or after this RFC 3339 time",
+                        "name": "updatedAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows changed before this RFC 3339 time",
+                        "name": "updatedBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated createdAt, updatedAt, createdBy or updatedBy, each prefixed with - to descend",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.RoleAccess"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add a Role Access into Database, optionally restricted by conditions such as \"owner \u0026\u0026 ip in 10.0.0.0/8 \u0026\u0026 hours 09:00-18:00 Asia/Singapore\"",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "role-access"
+                ],
+                "summary": "Add a Role Access",
+                "parameters": [
+                    {
+                        "description": "Role Access Details",
+                        "name": "role-access",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RoleAccess"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RoleAccess"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON body or conditions",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Only a super-admin can grant admin access points",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Access point is not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a Role Access",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "role-access"
+                ],
+                "summary": "Delete a Role Access",
+                "parameters": [
+                    {
+                        "description": "Role ID",
+                        "name": "role_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    {
+                        "description": "Access Point ID",
+                        "name": "ap_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success"
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON object",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Only a super-admin can revoke admin access points",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Role access is not found given role_id and ap_id",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/role-conflicts": {
+            "get": {
+                "description": "Retrieves the sets of roles that no user may hold together",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "role-conflicts"
+                ],
+                "summary": "Get all Role Conflicts",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.RoleConflict"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Define a set of mutually exclusive roles. Existing holders are reported by /role-conflicts/violations",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "role-conflicts"
+                ],
+                "summary": "Add a Role Conflict",
+                "parameters": [
+                    {
+                        "description": "Role Conflict Details",
+                        "name": "role-conflict",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RoleConflict"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.RoleConflict"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON body or unknown role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/role-conflicts/violations": {
+            "get": {
+                "description": "Lists every user currently holding more than one role of the same conflict",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "role-conflicts"
+                ],
+                "summary": "Report Role Conflict violations",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.RoleConflictViolation"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/role-conflicts/{id}": {
+            "delete": {
+                "description": "Delete a Role Conflict by Id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "role-conflicts"
+                ],
+                "summary": "Delete a Role Conflict",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RoleConflict"
+                        }
+                    },
+                    "404": {
+                        "description": "Role conflict not found with Id",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/roles": {
+            "get": {
+                "description": "Retrieves a list of Roles",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Get all Roles",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only rows created by this user id",
+                        "name": "createdBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows last changed by this user id",
+                        "name": "updatedBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows created at or after this RFC 3339 time",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows created before this RFC 3339 time",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows changed at or after this RFC 3339 time",
+                        "name": "updatedAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only rows changed before this RFC 3339 time",
+                        "name": "updatedBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated createdAt, updatedAt, createdBy or updatedBy, each prefixed with - to descend",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Role"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add a Role into Database",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Add a Role",
+                "parameters": [
+                    {
+                        "description": "Role Details",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Role"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Role"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON body",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Rank or parent is ranked above the caller, or the caller holds no ranked role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/roles/{id}": {
+            "get": {
+                "description": "Retrieve a Role By RoleID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Get Role by Id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Role"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the role"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "RoleId cannot be empy",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Role not found with Id",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Update a Role By RoleId. rank and parentId keep their current values when left out",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Update Role Details by Id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the role the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Role"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request due to invalid JSON body",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Role, its new rank or its parent is ranked above the caller, or the caller holds no ranked role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Role not found with Id",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "412": {
+                        "description": "If-Match does not match the current ETag",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required when REQUIRE_IF_MATCH is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a Role By RoleID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Delete a Role by Id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the role the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
                     "200": {
                         "description": "Success"
                     },
@@ -648,6 +2243,74 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.HTTPError"
                         }
                     },
+                    "403": {
+                        "description": "Role is ranked above the caller, or the caller holds no ranked role",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Role not found with Id",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "412": {
+                        "description": "If-Match does not match the current ETag",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required when REQUIRE_IF_MATCH is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/roles/{id}/access": {
+            "get": {
+                "description": "Retrieve the Role's direct role_access grants and those inherited from its ancestors",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Get access granted to a Role",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Permission"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "RoleId must be a number",
+                        "schema": {
+                            "$ref": "#/definitions/models.HTTPError"
+                        }
+                    },
                     "404": {
                         "description": "Role not found with Id",
                         "schema": {
@@ -662,20 +2325,228 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "controllers.Input": {
+        }
+    },
+    "definitions": {
+        "controllers.Input": {
+            "type": "object",
+            "required": [
+                "roles"
+            ],
+            "properties": {
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "models.AccessPoint": {
+            "type": "object",
+            "required": [
+                "endpoint",
+                "name"
+            ],
+            "properties": {
+                "admin": {
+                    "description": "Admin access points can only be created, changed or granted by a super-admin",
+                    "type": "boolean"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
+                "endpoint": {
+                    "type": "string",
+                    "example": "/users/accounts/:id"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string",
+                    "example": "GET"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "updatedBy": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuthorizationCheck": {
+            "type": "object",
+            "required": [
+                "method",
+                "path"
+            ],
+            "properties": {
+                "method": {
+                    "type": "string",
+                    "example": "GET"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/users/accounts/42"
+                },
+                "resource": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "sourceIp": {
+                    "type": "string",
+                    "example": "10.0.0.1"
+                }
+            }
+        },
+        "models.AuthorizationDecision": {
+            "type": "object",
+            "properties": {
+                "accessPoint": {
+                    "$ref": "#/definitions/models.AccessPoint"
+                },
+                "allowed": {
+                    "type": "boolean"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "policy": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "role": {
+                    "$ref": "#/definitions/models.Role"
+                }
+            }
+        },
+        "models.AuthorizationRequest": {
+            "type": "object",
+            "required": [
+                "checks",
+                "subject"
+            ],
+            "properties": {
+                "checks": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/models.AuthorizationCheck"
+                    }
+                },
+                "subject": {
+                    "$ref": "#/definitions/models.AuthorizationSubject"
+                }
+            }
+        },
+        "models.AuthorizationResponse": {
+            "type": "object",
+            "properties": {
+                "decisions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AuthorizationDecision"
+                    }
+                },
+                "userId": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuthorizationSubject": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DataScope": {
             "type": "object",
             "required": [
-                "roles"
+                "roleId",
+                "targetRoleId"
             ],
             "properties": {
-                "roles": {
+                "roleId": {
+                    "type": "integer"
+                },
+                "targetRoleId": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "models.FieldPolicy": {
+            "type": "object",
+            "required": [
+                "field",
+                "roleId"
+            ],
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "enum": [
+                        "firstName",
+                        "lastName",
+                        "email",
+                        "role"
+                    ]
+                },
+                "roleId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Grant": {
+            "type": "object",
+            "properties": {
+                "alsoGrantedBy": {
                     "type": "array",
                     "items": {
-                        "type": "integer"
+                        "$ref": "#/definitions/models.GrantSource"
                     }
+                },
+                "conditions": {
+                    "type": "string"
+                },
+                "inherited": {
+                    "type": "boolean"
+                },
+                "roleId": {
+                    "type": "integer"
+                },
+                "roleName": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.GrantSource": {
+            "type": "object",
+            "properties": {
+                "inherited": {
+                    "type": "boolean"
+                },
+                "roleId": {
+                    "type": "integer"
+                },
+                "roleName": {
+                    "type": "string"
                 }
             }
         },
@@ -686,9 +2557,89 @@ const docTemplate = `{
                     "type": "integer",
                     "example": 400
                 },
+                "existingId": {
+                    "description": "ExistingId is the user already holding the email of a 409 response",
+                    "type": "string",
+                    "example": "8d2f0c7e-3b8a-4c1e-9f57-0d6b1e1f4a2c"
+                },
                 "message": {
                     "type": "string",
                     "example": "status bad request"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "token_expired"
+                }
+            }
+        },
+        "models.JobResult": {
+            "type": "object",
+            "properties": {
+                "affected": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "job": {
+                    "type": "string",
+                    "example": "sweep-role-assignments"
+                }
+            }
+        },
+        "models.PIIPolicy": {
+            "type": "object",
+            "required": [
+                "action",
+                "field",
+                "roleId"
+            ],
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "mask",
+                        "omit"
+                    ]
+                },
+                "field": {
+                    "type": "string",
+                    "enum": [
+                        "firstName",
+                        "lastName",
+                        "email"
+                    ]
+                },
+                "roleId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Pagination": {
+            "type": "object",
+            "properties": {
+                "next": {
+                    "type": "string"
+                },
+                "prev": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 42
+                }
+            }
+        },
+        "models.Permission": {
+            "type": "object",
+            "properties": {
+                "accessPoint": {
+                    "$ref": "#/definitions/models.AccessPoint"
+                },
+                "grant": {
+                    "$ref": "#/definitions/models.Grant"
                 }
             }
         },
@@ -698,11 +2649,32 @@ const docTemplate = `{
                 "name"
             ],
             "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
                 "name": {
                     "type": "string"
+                },
+                "parentId": {
+                    "type": "integer"
+                },
+                "rank": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "updatedBy": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
                 }
             }
         },
@@ -716,8 +2688,64 @@ const docTemplate = `{
                 "apId": {
                     "type": "integer"
                 },
+                "conditions": {
+                    "type": "string",
+                    "example": "owner \u0026\u0026 ip in 10.0.0.0/8"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
                 "roleId": {
                     "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "updatedBy": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RoleConflict": {
+            "type": "object",
+            "required": [
+                "name",
+                "roleIds"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "roleIds": {
+                    "type": "array",
+                    "minItems": 2,
+                    "uniqueItems": true,
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "models.RoleConflictViolation": {
+            "type": "object",
+            "properties": {
+                "conflict": {
+                    "$ref": "#/definitions/models.RoleConflict"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Role"
+                    }
+                },
+                "userId": {
+                    "type": "string"
                 }
             }
         },
@@ -729,6 +2757,16 @@ const docTemplate = `{
                 "lastName"
             ],
             "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
                 "email": {
                     "type": "string"
                 },
@@ -743,6 +2781,61 @@ const docTemplate = `{
                 },
                 "role": {
                     "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "updatedBy": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.UserPage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.User"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/models.Pagination"
+                }
+            }
+        },
+        "models.UserRole": {
+            "type": "object",
+            "required": [
+                "roleId"
+            ],
+            "properties": {
+                "roleId": {
+                    "type": "integer"
+                },
+                "userId": {
+                    "type": "string"
+                },
+                "validFrom": {
+                    "type": "string"
+                },
+                "validUntil": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UserSearchResult": {
+            "type": "object",
+            "properties": {
+                "score": {
+                    "type": "number",
+                    "example": 5.5
+                },
+                "user": {
+                    "$ref": "#/definitions/models.User"
                 }
             }
         }
@@ -1,15 +1,15 @@
 package middlewares
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
+	"user-storage/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lestrrat-go/jwx/jwa"
-	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jws"
 )
 
@@ -20,6 +20,12 @@ type ParsedUserClaim struct {
 }
 
 func DecodeJWT() gin.HandlerFunc {
+	return DecodeJWTWith(keySetFromEnv(), claimRulesFromEnv())
+}
+
+// DecodeJWTWith verifies the X-IDTOKEN header against the given key set and
+// claim rules.
+func DecodeJWTWith(keys *KeySet, rules ClaimRules) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.Request.Header.Get("X-IDTOKEN")
 		if auth == "" {
@@ -34,44 +40,17 @@ func DecodeJWT() gin.HandlerFunc {
 		// 	c.Abort()
 		// 	return
 		// }
-		var keysJWK = os.Getenv("JWT_SECRET")
-		setOfKeys, err := jwk.ParseString(keysJWK)
-		if err != nil {
-			c.String(http.StatusInternalServerError, fmt.Sprintf("Failed to create JWK: %s", err))
-			c.Abort()
-			return
-		}
-
-        // change this for id_token or access_token
-		privKey, success := setOfKeys.Get(0)
-		if !success {
-			c.String(http.StatusInternalServerError, "Could not find key at given index")
-			c.Abort()
-			return
-		}
-
-		pubkey, err := jwk.PublicKeyOf(privKey)
-		if err != nil {
-			c.String(http.StatusInternalServerError, fmt.Sprintf("Failed to get public key: %s", err.Error()))
-			c.Abort()
-			return
-		}
-  
-		verifiedToken, err := jws.Verify([]byte(auth), jwa.RS256, pubkey)
-		if err != nil {
-			c.String(http.StatusForbidden, fmt.Sprintf("Failed to verify token from HTTP request: %s", err.Error()))
-			c.Abort()
-			return
-		}
-
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		var parsedUser ParsedUserClaim
-        err = json.Unmarshal([]byte(verifiedToken), &parsedUser)
+		parsedUser, code, err := parseToken(c.Request.Context(), keys, rules, auth)
 		if err != nil {
-			c.String(http.StatusInternalServerError, fmt.Sprintf("Failed to unmarshal data: %s", err.Error()))
+			if claimErr, ok := err.(*ClaimError); ok {
+				c.JSON(code, models.HTTPError{
+					Code:    code,
+					Message: claimErr.Message,
+					Reason:  claimErr.Reason,
+				})
+			} else {
+				c.String(code, err.Error())
+			}
 			c.Abort()
 			return
 		}
@@ -88,6 +67,66 @@ func DecodeJWT() gin.HandlerFunc {
         }
 
         c.Set("userDetails", userDetails)
+        // Writes made with the request context are stamped with the caller
+        c.Request = c.Request.WithContext(models.WithActor(c.Request.Context(), parsedUser.UserId))
         c.Next()
 	}
 }
+
+// ParseToken verifies a raw ID token the same way DecodeJWT does and returns
+// its claims, for callers that receive tokens outside the X-IDTOKEN header.
+func ParseToken(ctx context.Context, token string) (*ParsedUserClaim, int, error) {
+	return parseToken(ctx, keySetFromEnv(), claimRulesFromEnv(), token)
+}
+
+func parseToken(ctx context.Context, keys *KeySet, rules ClaimRules, token string) (*ParsedUserClaim, int, error) {
+	verifiedToken, code, err := verifyToken(ctx, keys, token)
+	if err != nil {
+		return nil, code, err
+	}
+
+	if err := rules.Validate(verifiedToken); err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+
+	var parsedUser ParsedUserClaim
+	if err := json.Unmarshal(verifiedToken, &parsedUser); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to unmarshal data: %s", err.Error())
+	}
+
+	return &parsedUser, http.StatusOK, nil
+}
+
+// verifyToken checks the token signature with the key named by its kid header
+// and returns the verified payload.
+func verifyToken(ctx context.Context, keys *KeySet, token string) ([]byte, int, error) {
+	msg, err := jws.ParseString(token)
+	if err != nil {
+		return nil, http.StatusForbidden, fmt.Errorf("Failed to parse token from HTTP request: %s", err.Error())
+	}
+	signatures := msg.Signatures()
+	if len(signatures) != 1 {
+		return nil, http.StatusForbidden, errors.New("Token must carry exactly one signature")
+	}
+
+	kid := signatures[0].ProtectedHeaders().KeyID()
+	pubkey, err := keys.LookupKey(ctx, kid)
+	if err != nil {
+		if errors.Is(err, ErrUnknownKeyID) {
+			return nil, http.StatusForbidden, fmt.Errorf("Failed to verify token from HTTP request: %s", err.Error())
+		}
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to get public key: %s", err.Error())
+	}
+
+	alg := jwa.RS256
+	if pubkey.Algorithm() != "" {
+		alg = jwa.SignatureAlgorithm(pubkey.Algorithm())
+	}
+
+	verifiedToken, err := jws.Verify([]byte(token), alg, pubkey)
+	if err != nil {
+		return nil, http.StatusForbidden, fmt.Errorf("Failed to verify token from HTTP request: %s", err.Error())
+	}
+
+	return verifiedToken, http.StatusOK, nil
+}
@@ -0,0 +1,227 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSigner struct {
+    kid  string
+    priv *rsa.PrivateKey
+}
+
+func newTestSigner(t *testing.T, kid string) testSigner {
+    priv, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil {
+        t.Fatalf("Error generating RSA key: %v", err)
+    }
+    return testSigner{kid: kid, priv: priv}
+}
+
+func (s testSigner) publicJWK(t *testing.T) jwk.Key {
+    key, err := jwk.New(&s.priv.PublicKey)
+    if err != nil {
+        t.Fatalf("Error creating JWK: %v", err)
+    }
+    key.Set(jwk.KeyIDKey, s.kid)
+    key.Set(jwk.AlgorithmKey, jwa.RS256)
+    return key
+}
+
+// sign issues a token that is valid for an hour unless claims override exp.
+func (s testSigner) sign(t *testing.T, claims map[string]interface{}) string {
+    if _, ok := claims["exp"]; !ok {
+        claims["exp"] = time.Now().Add(time.Hour).Unix()
+    }
+    payload, err := json.Marshal(claims)
+    if err != nil {
+        t.Fatal(err)
+    }
+    headers := jws.NewHeaders()
+    headers.Set(jws.KeyIDKey, s.kid)
+    token, err := jws.Sign(payload, jwa.RS256, s.priv, jws.WithHeaders(headers))
+    if err != nil {
+        t.Fatalf("Error signing token: %v", err)
+    }
+    return string(token)
+}
+
+// jwksServer serves whichever keys are currently published and counts fetches.
+type jwksServer struct {
+    mu      sync.Mutex
+    keys    []jwk.Key
+    fetches int
+}
+
+func (s *jwksServer) publish(keys ...jwk.Key) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.keys = keys
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.fetches++
+    set := jwk.NewSet()
+    for _, key := range s.keys {
+        set.Add(key)
+    }
+    json.NewEncoder(w).Encode(set)
+}
+
+func performAuthRequest(keys *KeySet, token string) (*httptest.ResponseRecorder, map[string]interface{}) {
+    return performAuthRequestWithRules(keys, ClaimRules{}, token)
+}
+
+func performAuthRequestWithRules(keys *KeySet, rules ClaimRules, token string) (*httptest.ResponseRecorder, map[string]interface{}) {
+    gin.SetMode(gin.TestMode)
+    var details map[string]interface{}
+    router := gin.New()
+    router.GET("/", DecodeJWTWith(keys, rules), func(c *gin.Context) {
+        data, _ := c.Get("userDetails")
+        details, _ = data.(map[string]interface{})
+        c.Status(http.StatusOK)
+    })
+
+    w := httptest.NewRecorder()
+    req := httptest.NewRequest(http.MethodGet, "/", nil)
+    req.Header.Set("X-IDTOKEN", token)
+    router.ServeHTTP(w, req)
+    return w, details
+}
+
+func TestDecodeJWT_SelectsKeyByKid(t *testing.T) {
+    first, second := newTestSigner(t, "key-1"), newTestSigner(t, "key-2")
+    jwks := &jwksServer{}
+    jwks.publish(first.publicJWK(t), second.publicJWK(t))
+    server := httptest.NewServer(jwks)
+    defer server.Close()
+
+    keys := NewKeySet(server.URL, time.Hour)
+    token := second.sign(t, map[string]interface{}{"user_id": "42", "email": "a@b.com", "role": "Admin"})
+
+    w, details := performAuthRequest(keys, token)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.Equal(t, "42", details["user_id"])
+    assert.Equal(t, "Admin", details["role"])
+}
+
+func TestDecodeJWT_RefetchesOnRotatedKey(t *testing.T) {
+    old, rotated := newTestSigner(t, "old"), newTestSigner(t, "rotated")
+    jwks := &jwksServer{}
+    jwks.publish(old.publicJWK(t))
+    server := httptest.NewServer(jwks)
+    defer server.Close()
+
+    keys := NewKeySet(server.URL, time.Hour)
+
+    w, _ := performAuthRequest(keys, old.sign(t, map[string]interface{}{"user_id": "1"}))
+    assert.Equal(t, http.StatusOK, w.Code)
+
+    // Issuer rotates keys; the cached set has not expired yet
+    jwks.publish(old.publicJWK(t), rotated.publicJWK(t))
+
+    w, details := performAuthRequest(keys, rotated.sign(t, map[string]interface{}{"user_id": "2"}))
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.Equal(t, "2", details["user_id"])
+    assert.Equal(t, 2, jwks.fetches)
+}
+
+func TestDecodeJWT_UnknownKid(t *testing.T) {
+    known, stranger := newTestSigner(t, "known"), newTestSigner(t, "stranger")
+    jwks := &jwksServer{}
+    jwks.publish(known.publicJWK(t))
+    server := httptest.NewServer(jwks)
+    defer server.Close()
+
+    keys := NewKeySet(server.URL, time.Hour)
+
+    w, _ := performAuthRequest(keys, stranger.sign(t, map[string]interface{}{"user_id": "1"}))
+    assert.Equal(t, http.StatusForbidden, w.Code)
+
+    // A second unknown kid inside the refetch window must not hit the issuer again
+    w, _ = performAuthRequest(keys, stranger.sign(t, map[string]interface{}{"user_id": "1"}))
+    assert.Equal(t, http.StatusForbidden, w.Code)
+    assert.Equal(t, 2, jwks.fetches)
+}
+
+func TestDecodeJWT_MissingHeader(t *testing.T) {
+    keys := NewStaticKeySet(`{"keys":[]}`)
+
+    w, _ := performAuthRequest(keys, "")
+
+    assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestDecodeJWT_ClaimValidation(t *testing.T) {
+    signer := newTestSigner(t, "key-1")
+    jwks := &jwksServer{}
+    jwks.publish(signer.publicJWK(t))
+    server := httptest.NewServer(jwks)
+    defer server.Close()
+
+    keys := NewKeySet(server.URL, time.Hour)
+    now := time.Now()
+    rules := ClaimRules{
+        Issuer:    "https://issuer.example.com",
+        Audiences: []string{"web-client"},
+        TokenUse:  "id",
+        ClockSkew: time.Minute,
+    }
+    valid := func() map[string]interface{} {
+        return map[string]interface{}{
+            "user_id":   "1",
+            "iss":       "https://issuer.example.com",
+            "aud":       "web-client",
+            "token_use": "id",
+            "iat":       now.Unix(),
+            "exp":       now.Add(time.Hour).Unix(),
+        }
+    }
+
+    tests := []struct {
+        name   string
+        modify func(map[string]interface{})
+        code   int
+        reason string
+    }{
+        {"valid", func(m map[string]interface{}) {}, http.StatusOK, ""},
+        {"expired within skew", func(m map[string]interface{}) { m["exp"] = now.Add(-30 * time.Second).Unix() }, http.StatusOK, ""},
+        {"expired", func(m map[string]interface{}) { m["exp"] = now.Add(-time.Hour).Unix() }, http.StatusUnauthorized, ReasonTokenExpired},
+        {"not yet valid", func(m map[string]interface{}) { m["nbf"] = now.Add(time.Hour).Unix() }, http.StatusUnauthorized, ReasonTokenNotYetValid},
+        {"issued in future", func(m map[string]interface{}) { m["iat"] = now.Add(time.Hour).Unix() }, http.StatusUnauthorized, ReasonTokenIssuedLater},
+        {"wrong issuer", func(m map[string]interface{}) { m["iss"] = "https://evil.example.com" }, http.StatusUnauthorized, ReasonInvalidIssuer},
+        {"wrong audience", func(m map[string]interface{}) { m["aud"] = []string{"other-client"} }, http.StatusUnauthorized, ReasonInvalidAudience},
+        {"access token", func(m map[string]interface{}) { m["token_use"] = "access" }, http.StatusUnauthorized, ReasonInvalidTokenUse},
+    }
+
+    for _, tc := range tests {
+        t.Run(tc.name, func(t *testing.T) {
+            claims := valid()
+            tc.modify(claims)
+
+            w, _ := performAuthRequestWithRules(keys, rules, signer.sign(t, claims))
+
+            assert.Equal(t, tc.code, w.Code)
+            if tc.reason != "" {
+                var body map[string]interface{}
+                json.Unmarshal(w.Body.Bytes(), &body)
+                assert.Equal(t, tc.reason, body["reason"])
+            }
+        })
+    }
+}
@@ -0,0 +1,148 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"user-storage/models"
+	"user-storage/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Authorize must run after DecodeJWT. It rejects the request with 403 unless
+// the caller's role is granted an access point matching the request through
+// role_access, and the conditions on that grant hold, or a policy allows it.
+// A policy denial always rejects the request.
+func Authorize(db *gorm.DB) gin.HandlerFunc {
+	accessService := services.NewAccessService(db)
+
+	return func(c *gin.Context) {
+		userId, ok := callerId(c)
+		if !ok {
+			return
+		}
+
+		data, _ := c.Get("userDetails")
+		claims, _ := data.(map[string]interface{})
+		email, _ := claims["email"].(string)
+		role, _ := claims["role"].(string)
+		subject := models.PolicySubject{UserId: userId, Email: email, Role: role}
+		check := models.AuthorizationCheck{
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			SourceIP: sourceIP(c),
+			Resource: routeResource(c),
+		}
+
+		// A caller without an account is denied like any other
+		res, code, err := accessService.Decide(subject, []models.AuthorizationCheck{check})
+		if err != nil {
+			c.JSON(code, models.HTTPError{
+				Code:    code,
+				Message: fmt.Sprintf("Unable to check permissions. %v", err.Error()),
+			})
+			c.Abort()
+			return
+		}
+		if !res.Decisions[0].Allowed {
+			message := fmt.Sprintf("Not permitted to %s %s", c.Request.Method, c.Request.URL.Path)
+			if res.Decisions[0].Reason != "" {
+				message = fmt.Sprintf("%s. %s", message, res.Decisions[0].Reason)
+			}
+			c.JSON(http.StatusForbidden, models.HTTPError{
+				Code:    http.StatusForbidden,
+				Message: message,
+				Reason:  "access_denied",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAdmin must run after DecodeJWT. It only lets through callers whose
+// role is listed in ADMIN_ROLES. It guards the permission model itself, so it
+// does not depend on role_access grants that the caller could have edited.
+func RequireAdmin(db *gorm.DB) gin.HandlerFunc {
+	accessService := services.NewAccessService(db)
+
+	return func(c *gin.Context) {
+		userId, ok := callerId(c)
+		if !ok {
+			return
+		}
+
+		isAdmin, code, err := accessService.IsAdmin(userId)
+		if err != nil {
+			c.JSON(code, models.HTTPError{
+				Code:    code,
+				Message: fmt.Sprintf("Unable to check permissions. %v", err.Error()),
+			})
+			c.Abort()
+			return
+		}
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, models.HTTPError{
+				Code:    http.StatusForbidden,
+				Message: "Only administrators can manage access control",
+				Reason:  "admin_required",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// callerId returns the user_id set by DecodeJWT, aborting the request if it
+// is missing.
+func callerId(c *gin.Context) (string, bool) {
+	data, ok := c.Get("userDetails")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, models.HTTPError{
+			Code:    http.StatusInternalServerError,
+			Message: "Error",
+		})
+		c.Abort()
+		return "", false
+	}
+	userDetailsObj, ok := data.(map[string]interface{})
+	if !ok {
+		c.JSON(http.StatusInternalServerError, models.HTTPError{
+			Code:    http.StatusInternalServerError,
+			Message: "Error",
+		})
+		c.Abort()
+		return "", false
+	}
+	userId, _ := userDetailsObj["user_id"].(string)
+	return userId, true
+}
+
+// sourceIP prefers the address API Gateway saw over the one gin derives from
+// the connection, which behind Lambda is the proxy's.
+func sourceIP(c *gin.Context) string {
+	if metadata, ok := c.Request.Context().Value("RequestMetadata").(models.RequestMetadata); ok && metadata.SourceIP != "" {
+		return metadata.SourceIP
+	}
+	return c.ClientIP()
+}
+
+// routeResource describes the target of the request for policies: the route's
+// resource type, such as "accounts", and its path parameters.
+func routeResource(c *gin.Context) map[string]interface{} {
+	segments := strings.Split(strings.Trim(c.FullPath(), "/"), "/")
+	resource := map[string]interface{}{}
+	if len(segments) > 1 {
+		resource["type"] = segments[1]
+	}
+	for _, param := range c.Params {
+		resource[param.Key] = param.Value
+	}
+	return resource
+}
@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("Error creating mock DB: %v", err)
+    }
+    gormDB, err := gorm.Open(mysql.New(mysql.Config{
+        Conn:                      db,
+        DriverName:                "mysql",
+        SkipInitializeWithVersion: true,
+    }), &gorm.Config{SkipDefaultTransaction: true})
+    if err != nil {
+        t.Fatalf("Error creating GORM DB: %v", err)
+    }
+    return gormDB, mock
+}
+
+// performAdminRequest runs RequireAdmin for a caller with userDetails, or for
+// a request DecodeJWT never saw when userDetails is nil.
+func performAdminRequest(db *gorm.DB, userDetails map[string]interface{}) *httptest.ResponseRecorder {
+    gin.SetMode(gin.TestMode)
+    router := gin.New()
+    router.GET("/", func(c *gin.Context) {
+        if userDetails != nil {
+            c.Set("userDetails", userDetails)
+        }
+    }, RequireAdmin(db), func(c *gin.Context) {
+        c.Status(http.StatusOK)
+    })
+
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+    return w
+}
+
+const isAdminQuery = "SELECT count(*) FROM `user_roles` JOIN roles ON roles.id = user_roles.role_id WHERE (user_roles.user_id = ? AND roles.name IN (?))"
+
+func TestRequireAdmin_Allows(t *testing.T) {
+    db, mock := newMockDB(t)
+    mock.ExpectQuery(regexp.QuoteMeta(isAdminQuery)).
+        WithArgs("1", "Admin", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+    w := performAdminRequest(db, map[string]interface{}{"user_id": "1"})
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequireAdmin_Denies(t *testing.T) {
+    db, mock := newMockDB(t)
+    mock.ExpectQuery(regexp.QuoteMeta(isAdminQuery)).
+        WithArgs("2", "Admin", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+    w := performAdminRequest(db, map[string]interface{}{"user_id": "2"})
+
+    assert.Equal(t, http.StatusForbidden, w.Code)
+    assert.Contains(t, w.Body.String(), "admin_required")
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequireAdmin_MissingCaller(t *testing.T) {
+    db, mock := newMockDB(t)
+
+    w := performAdminRequest(db, nil)
+
+    assert.Equal(t, http.StatusInternalServerError, w.Code)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,152 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultClockSkew = time.Minute
+
+// Reasons returned to the client when a verified token fails claim validation
+const (
+	ReasonMalformedClaims  = "malformed_claims"
+	ReasonTokenExpired     = "token_expired"
+	ReasonTokenNotYetValid = "token_not_yet_valid"
+	ReasonTokenIssuedLater = "token_issued_in_future"
+	ReasonInvalidIssuer    = "invalid_issuer"
+	ReasonInvalidAudience  = "invalid_audience"
+	ReasonInvalidTokenUse  = "invalid_token_use"
+)
+
+// ClaimError describes why a token with a valid signature was rejected.
+type ClaimError struct {
+	Reason  string
+	Message string
+}
+
+func (e *ClaimError) Error() string {
+	return e.Message
+}
+
+// ClaimRules configures which registered claims a token must satisfy. Empty
+// Issuer, Audiences or TokenUse disable the corresponding check.
+type ClaimRules struct {
+	Issuer    string
+	Audiences []string
+	TokenUse  string
+	ClockSkew time.Duration
+	Now       func() time.Time
+}
+
+// audience accepts both the single string and the array form of "aud".
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+type registeredClaims struct {
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+	IssuedAt  *float64 `json:"iat"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	TokenUse  string   `json:"token_use"`
+	// Cognito access tokens carry the app client in client_id instead of aud
+	ClientId string `json:"client_id"`
+}
+
+// claimRulesFromEnv reads JWT_ISSUER, JWT_AUDIENCE (comma separated),
+// JWT_TOKEN_USE and JWT_CLOCK_SKEW.
+func claimRulesFromEnv() ClaimRules {
+	rules := ClaimRules{
+		Issuer:    os.Getenv("JWT_ISSUER"),
+		TokenUse:  os.Getenv("JWT_TOKEN_USE"),
+		ClockSkew: defaultClockSkew,
+	}
+	for _, aud := range strings.Split(os.Getenv("JWT_AUDIENCE"), ",") {
+		if aud = strings.TrimSpace(aud); aud != "" {
+			rules.Audiences = append(rules.Audiences, aud)
+		}
+	}
+	if raw := os.Getenv("JWT_CLOCK_SKEW"); raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil {
+			rules.ClockSkew = d
+		}
+	}
+	return rules
+}
+
+// Validate checks exp, nbf, iat, iss, aud and token_use of a verified payload.
+func (r ClaimRules) Validate(payload []byte) error {
+	var claims registeredClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return &ClaimError{ReasonMalformedClaims, fmt.Sprintf("Token claims are malformed: %s", err.Error())}
+	}
+
+	now := time.Now()
+	if r.Now != nil {
+		now = r.Now()
+	}
+
+	if claims.ExpiresAt == nil {
+		return &ClaimError{ReasonMalformedClaims, "Token has no expiry"}
+	}
+	if now.Add(-r.ClockSkew).After(unixTime(*claims.ExpiresAt)) {
+		return &ClaimError{ReasonTokenExpired, "Token expired"}
+	}
+	if claims.NotBefore != nil && now.Add(r.ClockSkew).Before(unixTime(*claims.NotBefore)) {
+		return &ClaimError{ReasonTokenNotYetValid, "Token is not valid yet"}
+	}
+	if claims.IssuedAt != nil && now.Add(r.ClockSkew).Before(unixTime(*claims.IssuedAt)) {
+		return &ClaimError{ReasonTokenIssuedLater, "Token was issued in the future"}
+	}
+
+	if r.Issuer != "" && claims.Issuer != r.Issuer {
+		return &ClaimError{ReasonInvalidIssuer, "Token was issued by an untrusted issuer"}
+	}
+
+	if len(r.Audiences) > 0 {
+		presented := append([]string{}, claims.Audience...)
+		if claims.ClientId != "" {
+			presented = append(presented, claims.ClientId)
+		}
+		if !containsAny(r.Audiences, presented) {
+			return &ClaimError{ReasonInvalidAudience, "Token was issued for a different audience"}
+		}
+	}
+
+	if r.TokenUse != "" && claims.TokenUse != r.TokenUse {
+		return &ClaimError{ReasonInvalidTokenUse, fmt.Sprintf("Expected an %s token", r.TokenUse)}
+	}
+
+	return nil
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+func containsAny(allowed, presented []string) bool {
+	for _, p := range presented {
+		for _, a := range allowed {
+			if p == a {
+				return true
+			}
+		}
+	}
+	return false
+}
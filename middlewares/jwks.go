@@ -0,0 +1,177 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	// Minimum gap between forced refetches triggered by an unknown kid, so a
+	// flood of forged tokens cannot hammer the JWKS endpoint.
+	minJWKSRefetchInterval = 30 * time.Second
+)
+
+var ErrUnknownKeyID = errors.New("no signing key found for token kid")
+
+// KeySet caches the JWK set used to verify ID tokens. Keys are loaded from a
+// JWKS URL, a local file or an inline JSON document, refreshed every refresh
+// interval and refetched early when a token references a kid we do not know.
+type KeySet struct {
+	source  string
+	loader  func(ctx context.Context) (jwk.Set, error)
+	refresh time.Duration
+
+	mu          sync.RWMutex
+	set         jwk.Set
+	fetchedAt   time.Time
+	lastRefetch time.Time
+}
+
+// NewKeySet creates a KeySet for the given source. Sources starting with
+// http:// or https:// are fetched over HTTP, anything else is read as a file.
+func NewKeySet(source string, refresh time.Duration) *KeySet {
+	if refresh <= 0 {
+		refresh = defaultJWKSRefreshInterval
+	}
+	ks := &KeySet{source: source, refresh: refresh}
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		ks.loader = func(ctx context.Context) (jwk.Set, error) {
+			return jwk.Fetch(ctx, source)
+		}
+	} else {
+		ks.loader = func(ctx context.Context) (jwk.Set, error) {
+			data, err := os.ReadFile(source)
+			if err != nil {
+				return nil, err
+			}
+			return jwk.Parse(data)
+		}
+	}
+	return ks
+}
+
+// NewStaticKeySet wraps an inline JWK set document that is never refetched.
+func NewStaticKeySet(raw string) *KeySet {
+	return &KeySet{
+		source:  "inline",
+		refresh: defaultJWKSRefreshInterval,
+		loader: func(ctx context.Context) (jwk.Set, error) {
+			return jwk.ParseString(raw)
+		},
+	}
+}
+
+// LookupKey returns the public key matching kid. When kid is empty the set must
+// contain exactly one key, which keeps single-key setups working.
+func (k *KeySet) LookupKey(ctx context.Context, kid string) (jwk.Key, error) {
+	set, err := k.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := findKey(set, kid)
+	if !ok && kid != "" && k.allowRefetch() {
+		// The issuer may have rotated its keys since our last fetch
+		set, err = k.reload(ctx)
+		if err != nil {
+			return nil, err
+		}
+		key, ok = findKey(set, kid)
+	}
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+
+	return jwk.PublicKeyOf(key)
+}
+
+func (k *KeySet) current(ctx context.Context) (jwk.Set, error) {
+	k.mu.RLock()
+	set, fetchedAt := k.set, k.fetchedAt
+	k.mu.RUnlock()
+
+	if set != nil && time.Since(fetchedAt) < k.refresh {
+		return set, nil
+	}
+
+	fresh, err := k.reload(ctx)
+	if err != nil {
+		// Keep serving the last known keys if the issuer is briefly unreachable
+		if set != nil {
+			return set, nil
+		}
+		return nil, err
+	}
+	return fresh, nil
+}
+
+func (k *KeySet) reload(ctx context.Context) (jwk.Set, error) {
+	set, err := k.loader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWK set from %s: %w", k.source, err)
+	}
+
+	k.mu.Lock()
+	k.set = set
+	k.fetchedAt = time.Now()
+	k.mu.Unlock()
+
+	return set, nil
+}
+
+func (k *KeySet) allowRefetch() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if time.Since(k.lastRefetch) < minJWKSRefetchInterval {
+		return false
+	}
+	k.lastRefetch = time.Now()
+	return true
+}
+
+func findKey(set jwk.Set, kid string) (jwk.Key, bool) {
+	if kid == "" {
+		if set.Len() != 1 {
+			return nil, false
+		}
+		return set.Get(0)
+	}
+	return set.LookupKeyID(kid)
+}
+
+var (
+	defaultKeySet     *KeySet
+	defaultKeySetOnce sync.Once
+)
+
+// keySetFromEnv builds the process wide KeySet from JWKS_URL, JWKS_FILE or,
+// for older deployments, the inline JWT_SECRET document.
+func keySetFromEnv() *KeySet {
+	defaultKeySetOnce.Do(func() {
+		refresh := defaultJWKSRefreshInterval
+		if raw := os.Getenv("JWKS_REFRESH_INTERVAL"); raw != "" {
+			if d, err := time.ParseDuration(raw); err == nil {
+				refresh = d
+			}
+		}
+
+		switch {
+		case os.Getenv("JWKS_URL") != "":
+			defaultKeySet = NewKeySet(os.Getenv("JWKS_URL"), refresh)
+		case os.Getenv("JWKS_FILE") != "":
+			defaultKeySet = NewKeySet(os.Getenv("JWKS_FILE"), refresh)
+		default:
+			defaultKeySet = NewStaticKeySet(os.Getenv("JWT_SECRET"))
+		}
+	})
+	return defaultKeySet
+}
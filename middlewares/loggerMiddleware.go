@@ -42,7 +42,7 @@ func LoggingMiddleware() gin.HandlerFunc {
 
 		// Logs for user accounts
 		if strings.Contains(reqUri, "/accounts") && !strings.Contains(reqUri, "/accounts/with-roles") {
-			if reqMethod == http.MethodPost || reqMethod == http.MethodPut || reqMethod == http.MethodDelete {
+			if reqMethod == http.MethodPost || reqMethod == http.MethodPut || reqMethod == http.MethodPatch || reqMethod == http.MethodDelete {
 
 				user, _ := ctx.Get("user")
 				userValue, _ := user.(models.User)
@@ -68,26 +68,44 @@ func LoggingMiddleware() gin.HandlerFunc {
 				var updatedUserFields log.Fields
 				if reqMethod == http.MethodPost {
 					action = "add user"
-				} else if reqMethod == http.MethodPut {
+				} else if reqMethod == http.MethodPut || reqMethod == http.MethodPatch {
 					action = "update user details"
 					newUser, _ := ctx.Get("updatedUser")
 					newUserValue, _ := newUser.(models.User)
 					updatedUserFields = log.Fields{
-						"id": newUserValue.Id,
-						// "firstName": newUserValue.FirstName,
-						// "lastName":  newUserValue.LastName,
-						// "email":     newUserValue.Email,
+						"id":   newUserValue.Id,
 						"role": newUserValue.Role,
 					}
 				} else if reqMethod == http.MethodDelete {
 					action = "delete user"
 				}
 
+				// Role assignments on /accounts/:id/roles
+				if strings.Contains(ctx.FullPath(), "/accounts/:id/roles") {
+					userRole, _ := ctx.Get("userRole")
+					userRoleValue, _ := userRole.(models.UserRole)
+					userValue = models.User{Id: userRoleValue.UserId, Role: &userRoleValue.RoleId}
+					if reqMethod == http.MethodPost {
+						action = "assign user role"
+					} else {
+						action = "revoke user role"
+					}
+				}
+
+				// Restores of soft-deleted users on /accounts/:id/restore
+				if strings.Contains(ctx.FullPath(), "/accounts/:id/restore") {
+					action = "restore user"
+					restored, _ := ctx.Get("updatedUser")
+					restoredValue, _ := restored.(models.User)
+					updatedUserFields = log.Fields{
+						"id":   restoredValue.Id,
+						"role": restoredValue.Role,
+					}
+				}
+
+				// PII is never written to the audit log, only the ids it belongs to
 				userFields := log.Fields{
-					"id": userValue.Id,
-					// "firstName": userValue.FirstName,
-					// "lastName":  userValue.LastName,
-					// "email":     userValue.Email,
+					"id":   userValue.Id,
 					"role": userValue.Role,
 				}
 
@@ -107,13 +125,15 @@ func LoggingMiddleware() gin.HandlerFunc {
 		}
 
 		// Logs for roles
-		if strings.Contains(reqUri, "/roles") {
+		if strings.Contains(reqUri, "/roles") && !strings.Contains(reqUri, "/accounts") {
 			role, _ := ctx.Get("role")
 			roleValue, _ := role.(models.Role)
 
 			roleFields := log.Fields{
-				"id":   roleValue.Id,
-				"name": roleValue.Name,
+				"id":       roleValue.Id,
+				"name":     roleValue.Name,
+				"parentId": roleValue.ParentId,
+				"rank":     roleValue.Rank,
 			}
 
 			if reqMethod == http.MethodPost || reqMethod == http.MethodPut || reqMethod == http.MethodDelete {
@@ -145,8 +165,10 @@ func LoggingMiddleware() gin.HandlerFunc {
 					newRole, _ := ctx.Get("updatedRole")
 					newRoleValue, _ := newRole.(models.Role)
 					updatedRoleFields = log.Fields{
-						"id":   newRoleValue.Id,
-						"name": newRoleValue.Name,
+						"id":       newRoleValue.Id,
+						"name":     newRoleValue.Name,
+						"parentId": newRoleValue.ParentId,
+						"rank":     newRoleValue.Rank,
 					}
 				} else if reqMethod == http.MethodDelete {
 					action = "delete role"
@@ -167,6 +189,194 @@ func LoggingMiddleware() gin.HandlerFunc {
 			}
 		}
 
+		// Logs for access points
+		if strings.Contains(reqUri, "/access-points") {
+			if reqMethod == http.MethodPost || reqMethod == http.MethodPut || reqMethod == http.MethodDelete {
+				accessPoint, _ := ctx.Get("accessPoint")
+				accessPointValue, _ := accessPoint.(models.AccessPoint)
+
+				var action string
+				var updatedAccessPointFields log.Fields
+				if reqMethod == http.MethodPost {
+					action = "add access point"
+				} else if reqMethod == http.MethodPut {
+					action = "update access point"
+					newAccessPoint, _ := ctx.Get("updatedAccessPoint")
+					newAccessPointValue, _ := newAccessPoint.(models.AccessPoint)
+					updatedAccessPointFields = log.Fields{
+						"name":     newAccessPointValue.Name,
+						"method":   newAccessPointValue.Method,
+						"endpoint": newAccessPointValue.EndPoint,
+						"admin":    newAccessPointValue.Admin,
+					}
+				} else if reqMethod == http.MethodDelete {
+					action = "delete access point"
+				}
+
+				log.WithFields(log.Fields{
+					"METHOD":  reqMethod,
+					"URI":     reqUri,
+					"STATUS":  statusCode,
+					"LATENCY": latencyTime,
+					"ACTOR":   actorId(ctx),
+					"ACCESS_POINT_DETAILS": log.Fields{
+						"id":       accessPointValue.Id,
+						"name":     accessPointValue.Name,
+						"method":   accessPointValue.Method,
+						"endpoint": accessPointValue.EndPoint,
+						"admin":    accessPointValue.Admin,
+					},
+					"UPDATED_ACCESS_POINT_DETAILS": updatedAccessPointFields,
+					"ACTION":                       action,
+					"USER_AGENT":                   userAgent,
+					"SOURCE_IP":                    sourceIP,
+				}).Info("ACCESS POINT REQUEST")
+			}
+		}
+
+		// Logs for role access grants
+		if strings.Contains(reqUri, "/role-access") {
+			if reqMethod == http.MethodPost || reqMethod == http.MethodDelete {
+				roleAccess, _ := ctx.Get("roleAccess")
+				roleAccessValue, _ := roleAccess.(models.RoleAccess)
+
+				action := "grant role access"
+				if reqMethod == http.MethodDelete {
+					action = "revoke role access"
+				}
+
+				log.WithFields(log.Fields{
+					"METHOD":  reqMethod,
+					"URI":     reqUri,
+					"STATUS":  statusCode,
+					"LATENCY": latencyTime,
+					"ACTOR":   actorId(ctx),
+					"ROLE_ACCESS_DETAILS": log.Fields{
+						"roleId":     roleAccessValue.RoleId,
+						"apId":       roleAccessValue.APId,
+						"conditions": roleAccessValue.Conditions,
+					},
+					"ACTION":     action,
+					"USER_AGENT": userAgent,
+					"SOURCE_IP":  sourceIP,
+				}).Info("ROLE ACCESS REQUEST")
+			}
+		}
+
+		// Logs for separation-of-duties constraints
+		if strings.Contains(reqUri, "/role-conflicts") {
+			if reqMethod == http.MethodPost || reqMethod == http.MethodDelete {
+				roleConflict, _ := ctx.Get("roleConflict")
+				roleConflictValue, _ := roleConflict.(models.RoleConflict)
+
+				action := "add role conflict"
+				if reqMethod == http.MethodDelete {
+					action = "delete role conflict"
+				}
+
+				log.WithFields(log.Fields{
+					"METHOD":  reqMethod,
+					"URI":     reqUri,
+					"STATUS":  statusCode,
+					"LATENCY": latencyTime,
+					"ACTOR":   actorId(ctx),
+					"ROLE_CONFLICT_DETAILS": log.Fields{
+						"id":      roleConflictValue.Id,
+						"name":    roleConflictValue.Name,
+						"roleIds": roleConflictValue.RoleIds,
+					},
+					"ACTION":     action,
+					"USER_AGENT": userAgent,
+					"SOURCE_IP":  sourceIP,
+				}).Info("ROLE CONFLICT REQUEST")
+			}
+		}
+
+		// Logs for data scopes
+		if strings.Contains(reqUri, "/data-scopes") {
+			if reqMethod == http.MethodPost || reqMethod == http.MethodDelete {
+				dataScope, _ := ctx.Get("dataScope")
+				dataScopeValue, _ := dataScope.(models.DataScope)
+
+				action := "add data scope"
+				if reqMethod == http.MethodDelete {
+					action = "delete data scope"
+				}
+
+				log.WithFields(log.Fields{
+					"METHOD":  reqMethod,
+					"URI":     reqUri,
+					"STATUS":  statusCode,
+					"LATENCY": latencyTime,
+					"ACTOR":   actorId(ctx),
+					"DATA_SCOPE_DETAILS": log.Fields{
+						"roleId":       dataScopeValue.RoleId,
+						"targetRoleId": dataScopeValue.TargetRoleId,
+					},
+					"ACTION":     action,
+					"USER_AGENT": userAgent,
+					"SOURCE_IP":  sourceIP,
+				}).Info("DATA SCOPE REQUEST")
+			}
+		}
+
+		// Logs for field policies
+		if strings.Contains(reqUri, "/field-policies") {
+			if reqMethod == http.MethodPost || reqMethod == http.MethodDelete {
+				fieldPolicy, _ := ctx.Get("fieldPolicy")
+				fieldPolicyValue, _ := fieldPolicy.(models.FieldPolicy)
+
+				action := "add field policy"
+				if reqMethod == http.MethodDelete {
+					action = "delete field policy"
+				}
+
+				log.WithFields(log.Fields{
+					"METHOD":  reqMethod,
+					"URI":     reqUri,
+					"STATUS":  statusCode,
+					"LATENCY": latencyTime,
+					"ACTOR":   actorId(ctx),
+					"FIELD_POLICY_DETAILS": log.Fields{
+						"roleId": fieldPolicyValue.RoleId,
+						"field":  fieldPolicyValue.Field,
+					},
+					"ACTION":     action,
+					"USER_AGENT": userAgent,
+					"SOURCE_IP":  sourceIP,
+				}).Info("FIELD POLICY REQUEST")
+			}
+		}
+
+		// Logs for PII policies
+		if strings.Contains(reqUri, "/pii-policies") {
+			if reqMethod == http.MethodPost || reqMethod == http.MethodDelete {
+				piiPolicy, _ := ctx.Get("piiPolicy")
+				piiPolicyValue, _ := piiPolicy.(models.PIIPolicy)
+
+				action := "add PII policy"
+				if reqMethod == http.MethodDelete {
+					action = "delete PII policy"
+				}
+
+				log.WithFields(log.Fields{
+					"METHOD":  reqMethod,
+					"URI":     reqUri,
+					"STATUS":  statusCode,
+					"LATENCY": latencyTime,
+					"ACTOR":   actorId(ctx),
+					"PII_POLICY_DETAILS": log.Fields{
+						"roleId": piiPolicyValue.RoleId,
+						"field":  piiPolicyValue.Field,
+						"action": piiPolicyValue.Action,
+					},
+					"ACTION":     action,
+					"USER_AGENT": userAgent,
+					"SOURCE_IP":  sourceIP,
+				}).Info("PII POLICY REQUEST")
+			}
+		}
+
 		// if reqMethod == http.MethodGet {
 		// 	log.WithFields(log.Fields{
 		// 		"METHOD":     reqMethod,
@@ -179,3 +389,10 @@ func LoggingMiddleware() gin.HandlerFunc {
 		// }
 	}
 }
+
+// actorId returns the user_id of the authenticated caller, if any.
+func actorId(ctx *gin.Context) interface{} {
+	data, _ := ctx.Get("userDetails")
+	userDetailsObj, _ := data.(map[string]interface{})
+	return userDetailsObj["user_id"]
+}
@@ -3,7 +3,11 @@ package models
 type AccessPoint struct {
     Id        int       `json:"id"`
     Name      string    `json:"name" validate:"required"`
-	EndPoint  string	`json:"endpoint" gorm:"column:endpoint" validate:"required"`
+    Method    string    `json:"method" gorm:"default:ANY" example:"GET"`
+	EndPoint  string	`json:"endpoint" gorm:"column:endpoint" validate:"required" example:"/users/accounts/:id"`
+    // Admin access points can only be created, changed or granted by a super-admin
+    Admin     bool      `json:"admin" gorm:"column:is_admin;default:false"`
+    Stamps
 }
 
 func (AccessPoint) TableName() string {
@@ -0,0 +1,38 @@
+package models
+
+// AuthorizationSubject identifies who is asking, either by user id or by a raw
+// ID token that the service verifies itself.
+type AuthorizationSubject struct {
+    UserId    string    `json:"userId"`
+    Token     string    `json:"token"`
+}
+
+// AuthorizationCheck is a request to decide. SourceIP is only needed for
+// grants with an ip condition, which deny when it is missing. Resource
+// describes the target for policies.
+type AuthorizationCheck struct {
+    Method    string                  `json:"method" validate:"required" example:"GET"`
+    Path      string                  `json:"path" validate:"required" example:"/users/accounts/42"`
+    SourceIP  string                  `json:"sourceIp" example:"10.0.0.1"`
+    Resource  map[string]interface{}  `json:"resource"`
+}
+
+type AuthorizationRequest struct {
+    Subject   AuthorizationSubject  `json:"subject" validate:"required"`
+    Checks    []AuthorizationCheck  `json:"checks" validate:"required,min=1,dive"`
+}
+
+type AuthorizationDecision struct {
+    Method      string          `json:"method"`
+    Path        string          `json:"path"`
+    Allowed     bool            `json:"allowed"`
+    AccessPoint *AccessPoint    `json:"accessPoint"`
+    Role        *Role           `json:"role"`
+    Policy      string          `json:"policy,omitempty"`
+    Reason      string          `json:"reason,omitempty"`
+}
+
+type AuthorizationResponse struct {
+    UserId      string                  `json:"userId"`
+    Decisions   []AuthorizationDecision `json:"decisions"`
+}
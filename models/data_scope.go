@@ -0,0 +1,13 @@
+package models
+
+// DataScope lets users holding RoleId see users holding TargetRoleId, or users
+// without any role when TargetRoleId is 0. A role without data scopes sees
+// every user.
+type DataScope struct {
+    RoleId          int     `json:"roleId" gorm:"column:role_id;primaryKey" validate:"required"`
+    TargetRoleId    *int    `json:"targetRoleId" gorm:"column:target_role_id;primaryKey" validate:"required,min=0"`
+}
+
+func (DataScope) TableName() string {
+    return "data_scopes"
+}
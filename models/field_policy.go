@@ -0,0 +1,12 @@
+package models
+
+// FieldPolicy lets users holding RoleId write Field of a user, named as in the
+// user JSON. A role without field policies may write every field.
+type FieldPolicy struct {
+    RoleId  int     `json:"roleId" gorm:"column:role_id;primaryKey" validate:"required"`
+    Field   string  `json:"field" gorm:"column:field;primaryKey" validate:"required,oneof=firstName lastName email role"`
+}
+
+func (FieldPolicy) TableName() string {
+    return "field_policies"
+}
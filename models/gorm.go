@@ -36,9 +36,13 @@ func ConnectToDB() {
 	dbname := os.Getenv("DB_NAME")
 
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", user, password, hostname, port, dbname) + "?charset=utf8mb4&parseTime=True&loc=Local"
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	// TranslateError reports unique index violations as gorm.ErrDuplicatedKey
+	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: true})
 
 	if err != nil {
 		log.Fatal("Failed to connect to Database")
 	}
+	if err = RegisterStampCallbacks(DB); err != nil {
+		log.Fatal("Failed to register stamp callbacks")
+	}
 }
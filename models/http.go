@@ -3,4 +3,21 @@ package models
 type HTTPError struct {
 	Code    int    `json:"code" example:"400"`
 	Message string `json:"message" example:"status bad request"`
+	Reason  string `json:"reason,omitempty" example:"token_expired"`
+	// ExistingId is the user already holding the email of a 409 response
+	ExistingId string `json:"existingId,omitempty" example:"8d2f0c7e-3b8a-4c1e-9f57-0d6b1e1f4a2c"`
+}
+
+// Pagination describes a page of a cursor paginated listing. Next and Prev
+// are passed back as cursor to read the neighbouring pages.
+type Pagination struct {
+	Size  int    `json:"size" example:"10"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Total *int64 `json:"total,omitempty" example:"42"`
+}
+
+type UserPage struct {
+	Data       []User     `json:"data"`
+	Pagination Pagination `json:"pagination"`
 }
@@ -0,0 +1,14 @@
+package models
+
+// JobEvent is the payload a schedule invokes the Lambda function with to run
+// a maintenance job instead of serving a request.
+type JobEvent struct {
+    Job string `json:"job"`
+}
+
+// JobResult reports a run of a maintenance job and how many rows it changed,
+// or for reload-policies how many policies it loaded.
+type JobResult struct {
+    Job      string `json:"job" example:"sweep-role-assignments"`
+    Affected int    `json:"affected" example:"3"`
+}
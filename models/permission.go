@@ -0,0 +1,27 @@
+package models
+
+// Grant records which role_access row gave a user an access point. Inherited
+// is set when the row belongs to an ancestor of the role being resolved.
+// AlsoGrantedBy lists the other roles granting the same access point under the
+// same conditions, which keep it in effect if this grant is revoked.
+type Grant struct {
+    RoleId        int           `json:"roleId" gorm:"column:role_id"`
+    RoleName      string        `json:"roleName" gorm:"column:role_name"`
+    Conditions    string        `json:"conditions,omitempty" gorm:"column:conditions"`
+    Inherited     bool          `json:"inherited" gorm:"-"`
+    AlsoGrantedBy []GrantSource `json:"alsoGrantedBy,omitempty" gorm:"-"`
+}
+
+// GrantSource is a role whose role_access row grants an access point.
+type GrantSource struct {
+    RoleId    int     `json:"roleId"`
+    RoleName  string  `json:"roleName"`
+    Inherited bool    `json:"inherited"`
+}
+
+// Permission is an access point a user can call together with the grant that
+// produced it.
+type Permission struct {
+    AccessPoint AccessPoint `json:"accessPoint" gorm:"embedded"`
+    Grant       Grant       `json:"grant" gorm:"embedded"`
+}
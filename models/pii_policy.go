@@ -0,0 +1,13 @@
+package models
+
+// PIIPolicy masks or omits Field of the users returned to holders of RoleId.
+// Fields without a policy are returned in full.
+type PIIPolicy struct {
+    RoleId  int     `json:"roleId" gorm:"column:role_id;primaryKey" validate:"required"`
+    Field   string  `json:"field" gorm:"column:field;primaryKey" validate:"required,oneof=firstName lastName email"`
+    Action  string  `json:"action" gorm:"column:action" validate:"required,oneof=mask omit"`
+}
+
+func (PIIPolicy) TableName() string {
+    return "pii_policies"
+}
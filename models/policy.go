@@ -0,0 +1,33 @@
+package models
+
+// PolicySubject is the caller as policies see it: the ID token claims plus
+// the names of every role currently in effect.
+type PolicySubject struct {
+    UserId    string    `json:"user_id"`
+    Email     string    `json:"email"`
+    Role      string    `json:"role"`
+    Roles     []string  `json:"roles"`
+}
+
+type PolicyRequest struct {
+    Method    string              `json:"method"`
+    Path      string              `json:"path"`
+    SourceIP  string              `json:"sourceIp"`
+    Params    map[string]string   `json:"params"`
+}
+
+// PolicyInput is everything a policy engine decides on. Resource describes the
+// target of the request and is free-form.
+type PolicyInput struct {
+    Subject   PolicySubject           `json:"subject"`
+    Request   PolicyRequest           `json:"request"`
+    Resource  map[string]interface{}  `json:"resource"`
+}
+
+// PolicyResult is the outcome of evaluating policies. Effect is "allow",
+// "deny", or empty when no policy applies.
+type PolicyResult struct {
+    Effect    string    `json:"effect"`
+    Policy    string    `json:"policy"`
+    Reason    string    `json:"reason"`
+}
@@ -1,6 +1,21 @@
 package models
 
+import "gorm.io/gorm"
+
+// Role is a named set of grants. Rank orders roles by privilege: a caller can
+// only assign, revoke or edit roles ranked at or below their own highest rank,
+// and not at all while every role they hold is unranked at 0.
+// Version is bumped on every update and served as the ETag.
 type Role struct {
     Id        int       `json:"id"`
     Name      string    `json:"name" validate:"required"`
+    ParentId  *int      `json:"parentId" gorm:"column:parent_id;default:null"`
+    Rank      int       `json:"rank" gorm:"column:role_rank;default:0"`
+    Version   int       `json:"version" gorm:"column:version;default:1"`
+    Stamps
+}
+
+func (r *Role) BeforeCreate(tx *gorm.DB) error {
+    r.Version = 1
+    return nil
 }
@@ -1,8 +1,12 @@
 package models
 
+// RoleAccess grants a role an access point. Conditions optionally restricts
+// the grant, see services.Condition for the language.
 type RoleAccess struct {
-    RoleId    int    `json:"roleId" gorm:"column:role_id" validate:"required"`
-    APId      int    `json:"apId" gorm:"column:ap_id" validate:"required"`
+    RoleId      int     `json:"roleId" gorm:"column:role_id" validate:"required"`
+    APId        int     `json:"apId" gorm:"column:ap_id" validate:"required"`
+    Conditions  string  `json:"conditions" gorm:"column:conditions" example:"owner && ip in 10.0.0.0/8"`
+    Stamps
 }
 
 func (RoleAccess) TableName() string {
@@ -0,0 +1,29 @@
+package models
+
+// RoleConflict is a separation-of-duties rule: no user may hold more than one
+// of its roles at the same time.
+type RoleConflict struct {
+    Id        int       `json:"id"`
+    Name      string    `json:"name" validate:"required"`
+    RoleIds   []int     `json:"roleIds" gorm:"-" validate:"required,min=2,unique"`
+}
+
+func (RoleConflict) TableName() string {
+    return "role_conflicts"
+}
+
+type RoleConflictMember struct {
+    ConflictId  int     `json:"conflictId" gorm:"column:conflict_id;primaryKey"`
+    RoleId      int     `json:"roleId" gorm:"column:role_id;primaryKey"`
+}
+
+func (RoleConflictMember) TableName() string {
+    return "role_conflict_members"
+}
+
+// RoleConflictViolation is a user holding several roles of one RoleConflict.
+type RoleConflictViolation struct {
+    Conflict  RoleConflict  `json:"conflict"`
+    UserId    string        `json:"userId"`
+    Roles     []Role        `json:"roles"`
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Stamps records when and by whom a row was created and last changed. The
+// times are kept by gorm; the actors are taken from the context the write
+// runs with, see WithActor. Handlers clear whatever stamps a request body
+// carried, and the callbacks overwrite them on every write regardless.
+type Stamps struct {
+    CreatedAt time.Time `json:"createdAt"`
+    UpdatedAt time.Time `json:"updatedAt"`
+    CreatedBy string    `json:"createdBy" gorm:"size:64"`
+    UpdatedBy string    `json:"updatedBy" gorm:"size:64"`
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx whose writes are stamped with actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+    return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor, if any.
+func ActorFromContext(ctx context.Context) (string, bool) {
+    if ctx == nil {
+        return "", false
+    }
+    actor, ok := ctx.Value(actorKey{}).(string)
+    return actor, ok && actor != ""
+}
+
+// RegisterStampCallbacks fills CreatedBy and UpdatedBy on models embedding
+// Stamps, and keeps updates from rewriting CreatedAt and CreatedBy. The stamps
+// are always the server's: a write without an actor clears them rather than
+// keeping what the request body carried. Soft deletes stamp UpdatedAt and
+// UpdatedBy along with DeletedAt.
+func RegisterStampCallbacks(db *gorm.DB) error {
+    if err := db.Callback().Create().Before("gorm:create").Register("stamps:create", stampCreate); err != nil {
+        return err
+    }
+    if err := db.Callback().Update().Before("gorm:update").Register("stamps:update", stampUpdate); err != nil {
+        return err
+    }
+    if err := db.Callback().Delete().Before("gorm:delete").Register("stamps:delete", stampDelete); err != nil {
+        return err
+    }
+
+    // gorm builds the SET of updates and soft deletes itself, so the stamps
+    // are added to it as it is written out
+    build := db.ClauseBuilders["SET"]
+    db.ClauseBuilders["SET"] = func(c clause.Clause, builder clause.Builder) {
+        if stmt, ok := builder.(*gorm.Statement); ok {
+            if stamps, ok := stmt.Settings.LoadAndDelete(stampsKey); ok {
+                c.Expression = withStamps(c.Expression, stamps.(clause.Set))
+            }
+        }
+        if build != nil {
+            build(c, builder)
+            return
+        }
+        c.Build(builder)
+    }
+    return nil
+}
+
+// stampsKey holds, in the settings of a statement, the stamp assignments its
+// SET must carry.
+const stampsKey = "stamps:set"
+
+// withStamps returns the assignments of expression with stamps replacing any
+// assignment to the same columns.
+func withStamps(expression clause.Expression, stamps clause.Set) clause.Expression {
+    set, ok := expression.(clause.Set)
+    if !ok {
+        return expression
+    }
+    merged := make(clause.Set, 0, len(set)+len(stamps))
+    for _, assignment := range set {
+        stamped := false
+        for _, stamp := range stamps {
+            if assignment.Column.Name == stamp.Column.Name {
+                stamped = true
+                break
+            }
+        }
+        if !stamped {
+            merged = append(merged, assignment)
+        }
+    }
+    return append(merged, stamps...)
+}
+
+func stampCreate(db *gorm.DB) {
+    if db.Statement.Schema == nil || db.Statement.Schema.LookUpField("CreatedBy") == nil {
+        return
+    }
+    // Overwrite whatever the request body carried
+    now := db.Statement.DB.NowFunc()
+    actor, _ := ActorFromContext(db.Statement.Context)
+    db.Statement.SetColumn("CreatedAt", now, true)
+    db.Statement.SetColumn("UpdatedAt", now, true)
+    db.Statement.SetColumn("CreatedBy", actor, true)
+    db.Statement.SetColumn("UpdatedBy", actor, true)
+}
+
+func stampUpdate(db *gorm.DB) {
+    if db.Statement.Schema == nil || db.Statement.Schema.LookUpField("UpdatedBy") == nil {
+        return
+    }
+    db.Statement.Omits = append(db.Statement.Omits, "CreatedAt", "CreatedBy")
+    actor, _ := ActorFromContext(db.Statement.Context)
+    db.Statement.SetColumn("UpdatedBy", actor, true)
+    // Written even when empty, or left out by Select
+    db.Statement.Settings.Store(stampsKey, clause.Set{
+        {Column: clause.Column{Name: db.Statement.Schema.LookUpField("UpdatedBy").DBName}, Value: actor},
+    })
+}
+
+func stampDelete(db *gorm.DB) {
+    schema := db.Statement.Schema
+    if schema == nil || schema.LookUpField("UpdatedBy") == nil || db.Statement.Unscoped {
+        return
+    }
+    // Only soft deletes are updates that can carry stamps
+    if len(schema.DeleteClauses) == 0 {
+        return
+    }
+    now := db.Statement.DB.NowFunc()
+    actor, _ := ActorFromContext(db.Statement.Context)
+    db.Statement.SetColumn("UpdatedAt", now, true)
+    db.Statement.SetColumn("UpdatedBy", actor, true)
+    db.Statement.Settings.Store(stampsKey, clause.Set{
+        {Column: clause.Column{Name: schema.LookUpField("UpdatedAt").DBName}, Value: now},
+        {Column: clause.Column{Name: schema.LookUpField("UpdatedBy").DBName}, Value: actor},
+    })
+}
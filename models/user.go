@@ -5,15 +5,32 @@ import (
 	"gorm.io/gorm"
 )
 
+// User.Role is the user's primary role, kept for existing clients. Every role
+// the user holds, including the primary one, is recorded in user_roles.
+// Personal fields are returned empty when the caller's PII policies omit
+// them. Version is bumped on every update and served as the ETag.
+// Deleting a user only sets DeletedAt, which hides them from every query until
+// they are restored or purged. They keep their role assignments and email
+// until then.
 type User struct {
     Id        string    `json:"id" gorm:"primaryKey;"`
     FirstName string    `json:"firstName" validate:"required"`
     LastName  string    `json:"lastName" validate:"required"`
     Email     string    `json:"email" validate:"required,email"`
     Role      *uint     `json:"role" gorm:"default:null"`
+    Version   int       `json:"version" gorm:"default:1"`
+    DeletedAt gorm.DeletedAt `json:"deletedAt" gorm:"index" swaggertype:"string" format:"date-time"`
+    Stamps
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (error){
     u.Id = uuid.NewString()
+    u.Version = 1
     return nil
 }
+
+// UserSearchResult is a user found by search, with its relevance score.
+type UserSearchResult struct {
+    User  User    `json:"user"`
+    Score float64 `json:"score" example:"5.5"`
+}
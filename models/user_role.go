@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// UserRole assigns a role to a user. A user may hold any number of roles.
+// ValidFrom and ValidUntil bound temporary grants; nil means unbounded.
+type UserRole struct {
+    UserId     string       `json:"userId" gorm:"column:user_id;primaryKey"`
+    RoleId     uint         `json:"roleId" gorm:"column:role_id;primaryKey" validate:"required"`
+    ValidFrom  *time.Time   `json:"validFrom" gorm:"column:valid_from;default:null"`
+    ValidUntil *time.Time   `json:"validUntil" gorm:"column:valid_until;default:null"`
+}
+
+func (UserRole) TableName() string {
+    return "user_roles"
+}
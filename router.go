@@ -2,24 +2,44 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"os"
+	"time"
 	"user-storage/controllers"
 	docs "user-storage/docs"
     swaggerFiles "github.com/swaggo/files"
     ginSwagger "github.com/swaggo/gin-swagger"
 	"user-storage/middlewares"
 	"user-storage/models"
+	"user-storage/services"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	ginadapter "github.com/awslabs/aws-lambda-go-api-proxy/gin"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
 )
 
 var ginLambda *ginadapter.GinLambda
 
-func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+// Handler serves API Gateway requests, and runs a maintenance job when a
+// schedule invokes the function with a models.JobEvent instead.
+func Handler(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	var scheduled models.JobEvent
+	if err := json.Unmarshal(payload, &scheduled); err == nil && scheduled.Job != "" {
+		res, _, err := services.RunJob(ctx, models.DB, scheduled.Job, time.Now())
+		if err != nil {
+			log.WithError(err).WithField("JOB", scheduled.Job).Error("Failed to run job")
+			return nil, err
+		}
+		return res, nil
+	}
+
+	var req events.APIGatewayProxyRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
 
 	metadata := models.RequestMetadata{
 		UserAgent: req.RequestContext.Identity.UserAgent,
@@ -60,6 +80,13 @@ func InitRoutes() {
 	router.Use(cors.Default())
 	router.Use(middlewares.LoggingMiddleware())
 
+	// Authorization policies written as code, checked alongside role_access
+	policies, err := services.PolicyEngineFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load policies: %v", err)
+	}
+	services.DefaultPolicyEngine = policies
+
     health := new(controllers.HealthController)
 	user := controllers.NewUserController(*models.DB)
 
@@ -72,28 +99,37 @@ func InitRoutes() {
 
 	// Account Routes
 	usersGroup := v1.Group("/accounts")
-	usersGroup.Use(middlewares.DecodeJWT())
+	usersGroup.Use(middlewares.DecodeJWT(), middlewares.Authorize(models.DB))
 
 	usersGroup.GET("", user.GetAllUsers)
 	usersGroup.GET("/paginate", user.GetPaginatedUsers)
+	usersGroup.GET("/search", user.SearchUsers)
+	usersGroup.GET("/by-email/:email", user.GetUserByEmail)
 	usersGroup.GET("/:id", user.GetUserByID)
+	usersGroup.GET("/:id/permissions", user.GetUserPermissions)
+	usersGroup.GET("/:id/roles", user.GetUserRoles)
 
 	usersGroup.POST("", user.AddUser)
 	usersGroup.POST("/with-roles", user.GetUsersWithRole)
+	usersGroup.POST("/:id/roles", user.AddUserRole)
+	usersGroup.POST("/:id/restore", user.RestoreUserById)
 
 	usersGroup.PUT("/:id", user.UpdateUserById)
 
+	usersGroup.PATCH("/:id", user.PatchUserById)
+
 	usersGroup.DELETE("/:id", user.DeleteUserById)
+	usersGroup.DELETE("/:id/roles/:roleId", user.RemoveUserRole)
 
 	// Role Routes
 	role := new(controllers.RoleController)
 
 	rolesGroup := v1.Group("/roles")
+	rolesGroup.Use(middlewares.DecodeJWT(), middlewares.Authorize(models.DB))
 
 	rolesGroup.GET("", role.GetAllRoles)
 	rolesGroup.GET("/:id", role.GetRoleByID)
-
-	rolesGroup.Use(middlewares.DecodeJWT())
+	rolesGroup.GET("/:id/access", role.GetRoleAccess)
 
 	rolesGroup.POST("", role.AddRole)
 
@@ -105,8 +141,10 @@ func InitRoutes() {
 	accessPoint := new(controllers.AccessPointController)
 
 	accessPointsGroup := v1.Group("/access-points")
+	accessPointsGroup.Use(middlewares.DecodeJWT(), middlewares.RequireAdmin(models.DB))
 
 	accessPointsGroup.GET("", accessPoint.GetAllAccessPoints)
+	accessPointsGroup.GET("/match", accessPoint.MatchAccessPoints)
 	accessPointsGroup.GET("/:id", accessPoint.GetAccessPointByID)
 
 	accessPointsGroup.POST("", accessPoint.AddAccessPoint)
@@ -119,6 +157,7 @@ func InitRoutes() {
 	roleAccess := new(controllers.RoleAccessController)
 
 	roleAccessesGroup := v1.Group("/role-access")
+	roleAccessesGroup.Use(middlewares.DecodeJWT(), middlewares.RequireAdmin(models.DB))
 
 	roleAccessesGroup.GET("", roleAccess.GetAllRoleAccesses)
 
@@ -126,6 +165,72 @@ func InitRoutes() {
 
 	roleAccessesGroup.DELETE("", roleAccess.DeleteRoleAccess)
 
+	// Separation of duties
+	roleConflict := controllers.NewRoleConflictController(models.DB)
+
+	roleConflictsGroup := v1.Group("/role-conflicts")
+	roleConflictsGroup.Use(middlewares.DecodeJWT(), middlewares.RequireAdmin(models.DB))
+
+	roleConflictsGroup.GET("", roleConflict.GetAllRoleConflicts)
+	roleConflictsGroup.GET("/violations", roleConflict.GetViolations)
+
+	roleConflictsGroup.POST("", roleConflict.AddRoleConflict)
+
+	roleConflictsGroup.DELETE("/:id", roleConflict.DeleteRoleConflict)
+
+	// Row-level visibility of users
+	dataScope := controllers.NewDataScopeController(models.DB)
+
+	dataScopesGroup := v1.Group("/data-scopes")
+	dataScopesGroup.Use(middlewares.DecodeJWT(), middlewares.RequireAdmin(models.DB))
+
+	dataScopesGroup.GET("", dataScope.GetAllDataScopes)
+
+	dataScopesGroup.POST("", dataScope.AddDataScope)
+
+	dataScopesGroup.DELETE("", dataScope.DeleteDataScope)
+
+	// User fields each role may write
+	fieldPolicy := controllers.NewFieldPolicyController(models.DB)
+
+	fieldPoliciesGroup := v1.Group("/field-policies")
+	fieldPoliciesGroup.Use(middlewares.DecodeJWT(), middlewares.RequireAdmin(models.DB))
+
+	fieldPoliciesGroup.GET("", fieldPolicy.GetAllFieldPolicies)
+
+	fieldPoliciesGroup.POST("", fieldPolicy.AddFieldPolicy)
+
+	fieldPoliciesGroup.DELETE("", fieldPolicy.DeleteFieldPolicy)
+
+	// Masking of personal data in responses
+	piiPolicy := controllers.NewPIIPolicyController(models.DB)
+
+	piiPoliciesGroup := v1.Group("/pii-policies")
+	piiPoliciesGroup.Use(middlewares.DecodeJWT(), middlewares.RequireAdmin(models.DB))
+
+	piiPoliciesGroup.GET("", piiPolicy.GetAllPIIPolicies)
+
+	piiPoliciesGroup.POST("", piiPolicy.AddPIIPolicy)
+
+	piiPoliciesGroup.DELETE("", piiPolicy.DeletePIIPolicy)
+
+	// Policy decisions for other services
+	authorization := controllers.NewAuthorizationController(models.DB)
+
+	authorizeGroup := v1.Group("/authorize")
+	authorizeGroup.Use(middlewares.DecodeJWT())
+
+	authorizeGroup.POST("", authorization.Authorize)
+
+	// Maintenance jobs, for deployments without EventBridge schedules
+	job := controllers.NewJobController(models.DB)
+
+	jobsGroup := v1.Group("/jobs")
+	jobsGroup.Use(middlewares.DecodeJWT(), middlewares.RequireAdmin(models.DB))
+
+	jobsGroup.POST("/:name", job.RunJob)
+
+
     // Swagger
     router.GET("swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"user-storage/models"
+
+	"gorm.io/gorm"
+)
+
+type AccessService struct {
+    DB       *gorm.DB
+    Policies PolicyEngine
+}
+
+func NewAccessService(db *gorm.DB) *AccessService {
+    return &AccessService{DB: db, Policies: DefaultPolicyEngine}
+}
+
+// GetUserRoles returns the ids of every role currently in effect for the user.
+func (t *AccessService) GetUserRoles(userId string) ([]uint, int, error) {
+    if userId == "" {
+        return nil, http.StatusBadRequest, errors.New("User ID cannot be empty")
+    }
+
+    var user models.User
+    err := t.DB.Select("id").First(&user, "id = ?", userId).Error
+    if err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, http.StatusNotFound, errors.New("User ID is not found")
+        }
+        return nil, http.StatusInternalServerError, err
+    }
+
+    var roleIds []uint
+    err = t.DB.Model(&models.UserRole{}).Scopes(ActiveRoleAssignments).
+        Where("user_id = ?", userId).
+        Order("role_id").
+        Pluck("role_id", &roleIds).Error
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+
+    return roleIds, http.StatusOK, nil
+}
+
+// IsAllowed reports whether the user's role holds a role_access grant for an
+// access point matching the method and concrete request path, whose conditions
+// hold for a request from sourceIP, and no policy denies it.
+func (t *AccessService) IsAllowed(userId, method, path, sourceIP string) (bool, int, error) {
+    res, code, err := t.Decide(models.PolicySubject{UserId: userId}, []models.AuthorizationCheck{{Method: method, Path: path, SourceIP: sourceIP}})
+    if err != nil {
+        return false, code, err
+    }
+
+    return res.Decisions[0].Allowed, http.StatusOK, nil
+}
+
+// MatchAccessPoints returns every access point that applies to a request with
+// the given method and concrete path.
+func (t *AccessService) MatchAccessPoints(method, path string) (*[]models.AccessPoint, int, error) {
+    var accessPoints []models.AccessPoint
+    if err := t.DB.Order("id").Find(&accessPoints).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+
+    matched := []models.AccessPoint{}
+    for _, accessPoint := range accessPoints {
+        if MatchAccessPoint(accessPoint, method, path) {
+            matched = append(matched, accessPoint)
+        }
+    }
+
+    return &matched, http.StatusOK, nil
+}
+
+// GetUserPermissions resolves every role of the user, and their ancestors,
+// through role_access and returns the access points they grant. Direct grants
+// take precedence over inherited ones for the same access point, and the other
+// roles granting it are listed in AlsoGrantedBy.
+func (t *AccessService) GetUserPermissions(userId string) (*[]models.Permission, int, error) {
+    roleIds, code, err := t.GetUserRoles(userId)
+    if err != nil {
+        return nil, code, err
+    }
+
+    return t.permissionsOf(roleIds)
+}
+
+func (t *AccessService) permissionsOf(roleIds []uint) (*[]models.Permission, int, error) {
+    roleService := NewRoleService(t.DB)
+    seen := map[grantKey]int{}
+    permissions := []models.Permission{}
+    for _, roleId := range roleIds {
+        grants, code, err := roleService.GetRoleGrants(int(roleId))
+        if err != nil {
+            if code == http.StatusNotFound {
+                continue
+            }
+            return nil, code, err
+        }
+        for _, grant := range *grants {
+            key := grantKey{grant.AccessPoint.Id, grant.Grant.Conditions}
+            if i, ok := seen[key]; ok {
+                if permissions[i].Grant.Inherited && !grant.Grant.Inherited {
+                    mergeGrant(&grant.Grant, permissions[i].Grant)
+                    permissions[i] = grant
+                } else {
+                    mergeGrant(&permissions[i].Grant, grant.Grant)
+                }
+                continue
+            }
+            seen[key] = len(permissions)
+            permissions = append(permissions, grant)
+        }
+    }
+
+    return &permissions, http.StatusOK, nil
+}
+
+// Decide evaluates each check against the subject's grants, then against the
+// policy engine if one is configured. A check is allowed when one of the
+// role's access points matches its method and concrete path and the
+// conditions of that grant hold, or when a policy allows it. A policy denial
+// overrides both. Denied decisions carry the reason. A subject without an
+// account is denied every check.
+func (t *AccessService) Decide(subject models.PolicySubject, checks []models.AuthorizationCheck) (*models.AuthorizationResponse, int, error) {
+    roleIds, code, err := t.GetUserRoles(subject.UserId)
+    if err != nil {
+        if code == http.StatusNotFound {
+            return denyAll(subject.UserId, checks, "User is not found"), http.StatusOK, nil
+        }
+        return nil, code, err
+    }
+    permissions, code, err := t.permissionsOf(roleIds)
+    if err != nil {
+        return nil, code, err
+    }
+    if t.Policies != nil {
+        subject.Roles = []string{}
+        if len(roleIds) > 0 {
+            err := t.DB.Model(&models.Role{}).Where("id IN ?", roleIds).Order("id").Pluck("name", &subject.Roles).Error
+            if err != nil {
+                return nil, http.StatusInternalServerError, err
+            }
+        }
+    }
+
+    now := time.Now()
+    response := models.AuthorizationResponse{
+        UserId:    subject.UserId,
+        Decisions: make([]models.AuthorizationDecision, 0, len(checks)),
+    }
+    for _, check := range checks {
+        decision := decideFromGrants(*permissions, subject.UserId, check, now)
+
+        if t.Policies != nil {
+            result, err := t.Policies.Evaluate(models.PolicyInput{
+                Subject:  subject,
+                Request:  models.PolicyRequest{Method: check.Method, Path: check.Path, SourceIP: check.SourceIP},
+                Resource: check.Resource,
+            })
+            if err != nil {
+                return nil, http.StatusInternalServerError, err
+            }
+            switch result.Effect {
+            case PolicyDeny:
+                decision.Allowed = false
+                decision.AccessPoint = nil
+                decision.Role = nil
+                decision.Policy = result.Policy
+                decision.Reason = result.Reason
+            case PolicyAllow:
+                if !decision.Allowed {
+                    decision.Allowed = true
+                    decision.Policy = result.Policy
+                    decision.Reason = ""
+                }
+            }
+        }
+
+        response.Decisions = append(response.Decisions, decision)
+    }
+
+    return &response, http.StatusOK, nil
+}
+
+// denyAll answers every check with a denial for reason.
+func denyAll(userId string, checks []models.AuthorizationCheck, reason string) *models.AuthorizationResponse {
+    response := models.AuthorizationResponse{
+        UserId:    userId,
+        Decisions: make([]models.AuthorizationDecision, 0, len(checks)),
+    }
+    for _, check := range checks {
+        response.Decisions = append(response.Decisions, models.AuthorizationDecision{Method: check.Method, Path: check.Path, Reason: reason})
+    }
+    return &response
+}
+
+// decideFromGrants allows the check if a grant's access point matches it and
+// the grant's conditions hold. Otherwise the reason names the first condition
+// that failed.
+func decideFromGrants(permissions []models.Permission, userId string, check models.AuthorizationCheck, now time.Time) models.AuthorizationDecision {
+    decision := models.AuthorizationDecision{Method: check.Method, Path: check.Path}
+    for _, permission := range permissions {
+        params, ok := matchAccessPointParams(permission.AccessPoint, check.Method, check.Path)
+        if !ok {
+            continue
+        }
+
+        condition, err := ParseCondition(permission.Grant.Conditions)
+        if err != nil {
+            // Grants are validated when created, so fail closed on a bad row
+            decision.Reason = err.Error()
+            continue
+        }
+        env := ConditionEnv{UserId: userId, Params: params, SourceIP: check.SourceIP, Now: now}
+        if holds, failed := condition.Evaluate(env); !holds {
+            if decision.Reason == "" {
+                decision.Reason = fmt.Sprintf("Condition %q is not met", failed)
+            }
+            continue
+        }
+
+        accessPoint := permission.AccessPoint
+        decision.Allowed = true
+        decision.AccessPoint = &accessPoint
+        decision.Role = &models.Role{Id: permission.Grant.RoleId, Name: permission.Grant.RoleName}
+        decision.Reason = ""
+        break
+    }
+    return decision
+}
+
+// AdminRoles returns the role names allowed to manage the permission model,
+// read from the comma separated ADMIN_ROLES and defaulting to "Admin".
+func AdminRoles() []string {
+    var roles []string
+    for _, name := range strings.Split(os.Getenv("ADMIN_ROLES"), ",") {
+        if name = strings.TrimSpace(name); name != "" {
+            roles = append(roles, name)
+        }
+    }
+    if len(roles) == 0 {
+        roles = []string{"Admin"}
+    }
+    return roles
+}
+
+// IsAdmin reports whether the user holds one of AdminRoles.
+func (t *AccessService) IsAdmin(userId string) (bool, int, error) {
+    var count int64
+    err := t.DB.Model(&models.UserRole{}).
+        Joins("JOIN roles ON roles.id = user_roles.role_id").
+        Scopes(ActiveRoleAssignments).
+        Where("user_roles.user_id = ? AND roles.name IN ?", userId, AdminRoles()).
+        Count(&count).Error
+    if err != nil {
+        return false, http.StatusInternalServerError, err
+    }
+
+    return count > 0, http.StatusOK, nil
+}
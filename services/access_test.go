@@ -0,0 +1,152 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAllowed_Granted(t *testing.T) {
+    accessService := NewAccessService(gormDB)
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE id = ?")).
+        WithArgs("1").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `role_id` FROM `user_roles` WHERE user_id = ? AND ((user_roles.valid_from IS NULL OR user_roles.valid_from <= ?) AND (user_roles.valid_until IS NULL OR user_roles.valid_until > ?))")).
+        WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(2))
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `roles` WHERE id = ?")).
+        WithArgs(2).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(2, "Admin", nil))
+
+    statement := "SELECT access_points.*, roles.id AS role_id, roles.name AS role_name, role_access.conditions FROM `access_points` JOIN role_access ON role_access.ap_id = access_points.id JOIN roles ON roles.id = role_access.role_id WHERE role_access.role_id IN (?)"
+    mock.ExpectQuery(regexp.QuoteMeta(statement)).
+        WithArgs(2).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "method", "endpoint", "role_id", "role_name"}).
+            AddRow(1, "List users", "GET", "/users/accounts", 2, "Admin").
+            AddRow(2, "Delete user", "DELETE", "/users/accounts/:id", 2, "Admin"))
+
+    allowed, statusCode, err := accessService.IsAllowed("1", http.MethodDelete, "/users/accounts/42", "10.0.0.1")
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.True(t, allowed)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsAllowed_NoRole(t *testing.T) {
+    accessService := NewAccessService(gormDB)
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE id = ?")).
+        WithArgs("2").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("2"))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `role_id` FROM `user_roles` WHERE user_id = ? AND ((user_roles.valid_from IS NULL OR user_roles.valid_from <= ?) AND (user_roles.valid_until IS NULL OR user_roles.valid_until > ?))")).
+        WithArgs("2", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}))
+
+    allowed, statusCode, err := accessService.IsAllowed("2", http.MethodGet, "/users/accounts", "10.0.0.1")
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.False(t, allowed)
+}
+
+func TestMatchAccessPoint(t *testing.T) {
+    tests := []struct {
+        method, endpoint string
+        reqMethod, path  string
+        expected         bool
+    }{
+        {"ANY", "/users/accounts/:id", "GET", "/users/accounts/42", true},
+        {"ANY", "/users/accounts/:id", "DELETE", "/users/accounts/42", true},
+        {"DELETE", "/users/accounts/:id", "DELETE", "/users/accounts/42", true},
+        {"DELETE", "/users/accounts/:id", "GET", "/users/accounts/42", false},
+        {"GET", "/users/accounts/:id", "GET", "/users/accounts", false},
+        {"GET", "/users/accounts", "GET", "/users/accounts/", true},
+        {"GET", "/users/roles", "GET", "/users/accounts", false},
+        {"ANY", "/users/accounts/*", "PUT", "/users/accounts/42", true},
+        {"ANY", "/users/accounts/*", "GET", "/users/accounts/42/permissions", true},
+        {"ANY", "/users/accounts/*", "GET", "/users/roles/1", false},
+    }
+
+    for _, tc := range tests {
+        accessPoint := models.AccessPoint{Method: tc.method, EndPoint: tc.endpoint}
+        assert.Equal(t, tc.expected, MatchAccessPoint(accessPoint, tc.reqMethod, tc.path), "%s %s vs %s %s", tc.method, tc.endpoint, tc.reqMethod, tc.path)
+    }
+}
+
+func TestNormalizeAccessPoint(t *testing.T) {
+    accessPoint := models.AccessPoint{Name: "Get user", Method: "get", EndPoint: "/users/accounts/:id"}
+    assert.NoError(t, NormalizeAccessPoint(&accessPoint))
+    assert.Equal(t, "GET", accessPoint.Method)
+
+    accessPoint = models.AccessPoint{Name: "Everything", EndPoint: "/users/*"}
+    assert.NoError(t, NormalizeAccessPoint(&accessPoint))
+    assert.Equal(t, MethodAny, accessPoint.Method)
+
+    invalid := []models.AccessPoint{
+        {Method: "FETCH", EndPoint: "/users"},
+        {EndPoint: "users/accounts"},
+        {EndPoint: "/users/*/roles"},
+        {EndPoint: "/users/:id/:id"},
+        {EndPoint: "/users/:1d"},
+        {EndPoint: "/users/acc ounts"},
+    }
+    for _, accessPoint := range invalid {
+        assert.Error(t, NormalizeAccessPoint(&accessPoint), accessPoint.EndPoint)
+    }
+}
+
+func TestIsAdmin(t *testing.T) {
+    accessService := NewAccessService(gormDB)
+    statement := "SELECT count(*) FROM `user_roles` JOIN roles ON roles.id = user_roles.role_id WHERE (user_roles.user_id = ? AND roles.name IN (?))"
+
+    mock.ExpectQuery(regexp.QuoteMeta(statement)).
+        WithArgs("1", "Admin", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+    isAdmin, statusCode, err := accessService.IsAdmin("1")
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.True(t, isAdmin)
+
+    mock.ExpectQuery(regexp.QuoteMeta(statement)).
+        WithArgs("2", "Admin", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+    isAdmin, statusCode, err = accessService.IsAdmin("2")
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.False(t, isAdmin)
+
+    // A caller without an account holds no roles at all
+    mock.ExpectQuery(regexp.QuoteMeta(statement)).
+        WithArgs("", "Admin", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+    isAdmin, _, err = accessService.IsAdmin("")
+    assert.NoError(t, err)
+    assert.False(t, isAdmin)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDecide_UnknownUser(t *testing.T) {
+    accessService := NewAccessService(gormDB)
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE id = ?")).
+        WithArgs("404").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+    res, statusCode, err := accessService.Decide(models.PolicySubject{UserId: "404"}, []models.AuthorizationCheck{
+        {Method: http.MethodGet, Path: "/users/accounts"},
+    })
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Len(t, res.Decisions, 1)
+    assert.False(t, res.Decisions[0].Allowed)
+    assert.Equal(t, "User is not found", res.Decisions[0].Reason)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
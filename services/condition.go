@@ -0,0 +1,287 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"user-storage/models"
+
+	"gorm.io/gorm"
+
+	// Lambda images ship without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// A grant condition is a list of clauses joined by "&&", all of which must
+// hold for the grant to apply:
+//
+//	owner               the :id path parameter equals the caller's user_id
+//	owner(param)        the same, for another path parameter
+//	ip in <cidr>, ...   the request comes from one of the ranges or addresses
+//	hours HH:MM-HH:MM   the request falls inside the window, UTC by default;
+//	                    an IANA zone may follow and the window may wrap midnight
+//
+// For example "owner && ip in 10.0.0.0/8 && hours 09:00-18:00 Asia/Singapore".
+type Condition struct {
+    clauses []conditionClause
+}
+
+// ConditionEnv is the request a Condition is evaluated against.
+type ConditionEnv struct {
+    UserId   string
+    Params   map[string]string
+    SourceIP string
+    Now      time.Time
+}
+
+type conditionClause interface {
+    holds(env ConditionEnv) bool
+    String() string
+}
+
+// ParseCondition parses a grant condition. An empty expression always holds.
+func ParseCondition(expr string) (*Condition, error) {
+    condition := &Condition{}
+    if strings.TrimSpace(expr) == "" {
+        return condition, nil
+    }
+
+    for _, raw := range strings.Split(expr, "&&") {
+        raw = strings.TrimSpace(raw)
+        keyword, rest := raw, ""
+        if i := strings.IndexAny(raw, " (\t"); i != -1 {
+            keyword, rest = raw[:i], strings.TrimSpace(raw[i:])
+        }
+
+        var clause conditionClause
+        var err error
+        switch strings.ToLower(keyword) {
+        case "owner":
+            clause, err = parseOwnerClause(rest)
+        case "ip":
+            clause, err = parseIPClause(rest)
+        case "hours":
+            clause, err = parseHoursClause(rest)
+        case "":
+            err = errors.New("empty clause")
+        default:
+            err = fmt.Errorf("unknown clause %q", keyword)
+        }
+        if err != nil {
+            return nil, fmt.Errorf("Invalid condition %q: %v", raw, err)
+        }
+        condition.clauses = append(condition.clauses, clause)
+    }
+    return condition, nil
+}
+
+// Evaluate reports whether every clause holds, returning the first clause
+// that does not.
+func (c *Condition) Evaluate(env ConditionEnv) (bool, string) {
+    for _, clause := range c.clauses {
+        if !clause.holds(env) {
+            return false, clause.String()
+        }
+    }
+    return true, ""
+}
+
+// String returns the condition in canonical form.
+func (c *Condition) String() string {
+    clauses := make([]string, 0, len(c.clauses))
+    for _, clause := range c.clauses {
+        clauses = append(clauses, clause.String())
+    }
+    return strings.Join(clauses, " && ")
+}
+
+// ownerParams returns the path parameters named by owner clauses.
+func (c *Condition) ownerParams() []string {
+    var params []string
+    for _, clause := range c.clauses {
+        if owner, ok := clause.(ownerClause); ok {
+            params = append(params, owner.param)
+        }
+    }
+    return params
+}
+
+type ownerClause struct {
+    param string
+}
+
+func parseOwnerClause(rest string) (conditionClause, error) {
+    if rest == "" {
+        return ownerClause{param: "id"}, nil
+    }
+    if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+        return nil, errors.New("expected owner or owner(param)")
+    }
+    param := strings.TrimSpace(rest[1 : len(rest)-1])
+    if !paramSegment.MatchString(":" + param) {
+        return nil, fmt.Errorf("invalid parameter %q", param)
+    }
+    return ownerClause{param: param}, nil
+}
+
+func (o ownerClause) holds(env ConditionEnv) bool {
+    value := env.Params[o.param]
+    return value != "" && value == env.UserId
+}
+
+func (o ownerClause) String() string {
+    if o.param == "id" {
+        return "owner"
+    }
+    return fmt.Sprintf("owner(%s)", o.param)
+}
+
+type ipClause struct {
+    networks []*net.IPNet
+}
+
+func parseIPClause(rest string) (conditionClause, error) {
+    fields := strings.Fields(rest)
+    if len(fields) < 2 || strings.ToLower(fields[0]) != "in" {
+        return nil, errors.New("expected ip in <cidr>, ...")
+    }
+
+    clause := ipClause{}
+    for _, entry := range strings.Split(strings.TrimSpace(rest[len(fields[0]):]), ",") {
+        entry = strings.TrimSpace(entry)
+        if _, network, err := net.ParseCIDR(entry); err == nil {
+            clause.networks = append(clause.networks, network)
+            continue
+        }
+        ip := net.ParseIP(entry)
+        if ip == nil {
+            return nil, fmt.Errorf("invalid address or range %q", entry)
+        }
+        bits := 8 * net.IPv6len
+        if ip.To4() != nil {
+            ip, bits = ip.To4(), 8*net.IPv4len
+        }
+        clause.networks = append(clause.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+    }
+    return clause, nil
+}
+
+func (c ipClause) holds(env ConditionEnv) bool {
+    host := env.SourceIP
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    ip := net.ParseIP(strings.TrimSpace(host))
+    if ip == nil {
+        return false
+    }
+    for _, network := range c.networks {
+        if network.Contains(ip) {
+            return true
+        }
+    }
+    return false
+}
+
+func (c ipClause) String() string {
+    networks := make([]string, 0, len(c.networks))
+    for _, network := range c.networks {
+        networks = append(networks, network.String())
+    }
+    return "ip in " + strings.Join(networks, ", ")
+}
+
+type hoursClause struct {
+    start, end int
+    location   *time.Location
+}
+
+func parseHoursClause(rest string) (conditionClause, error) {
+    fields := strings.Fields(rest)
+    if len(fields) != 1 && len(fields) != 2 {
+        return nil, errors.New("expected hours HH:MM-HH:MM [zone]")
+    }
+
+    bounds := strings.Split(fields[0], "-")
+    if len(bounds) != 2 {
+        return nil, errors.New("expected hours HH:MM-HH:MM [zone]")
+    }
+    start, err := time.Parse("15:04", bounds[0])
+    if err != nil {
+        return nil, fmt.Errorf("invalid time %q", bounds[0])
+    }
+    end, err := time.Parse("15:04", bounds[1])
+    if err != nil {
+        return nil, fmt.Errorf("invalid time %q", bounds[1])
+    }
+
+    clause := hoursClause{
+        start:    start.Hour()*60 + start.Minute(),
+        end:      end.Hour()*60 + end.Minute(),
+        location: time.UTC,
+    }
+    if clause.start == clause.end {
+        return nil, errors.New("window is empty")
+    }
+    if len(fields) == 2 {
+        if clause.location, err = time.LoadLocation(fields[1]); err != nil {
+            return nil, fmt.Errorf("unknown time zone %q", fields[1])
+        }
+    }
+    return clause, nil
+}
+
+func (c hoursClause) holds(env ConditionEnv) bool {
+    now := env.Now.In(c.location)
+    minute := now.Hour()*60 + now.Minute()
+    if c.start < c.end {
+        return minute >= c.start && minute < c.end
+    }
+    return minute >= c.start || minute < c.end
+}
+
+func (c hoursClause) String() string {
+    window := fmt.Sprintf("hours %02d:%02d-%02d:%02d", c.start/60, c.start%60, c.end/60, c.end%60)
+    if c.location != time.UTC {
+        window += " " + c.location.String()
+    }
+    return window
+}
+
+// ValidateGrantConditions parses the conditions of a role_access grant,
+// rewriting them in canonical form, and checks that every owner clause names a
+// parameter of the granted access point's endpoint.
+func ValidateGrantConditions(db *gorm.DB, roleAccess *models.RoleAccess) (int, error) {
+    condition, err := ParseCondition(roleAccess.Conditions)
+    if err != nil {
+        return http.StatusBadRequest, err
+    }
+    roleAccess.Conditions = condition.String()
+
+    params := condition.ownerParams()
+    if len(params) == 0 {
+        return http.StatusOK, nil
+    }
+
+    var accessPoint models.AccessPoint
+    if err := db.First(&accessPoint, "id = ?", roleAccess.APId).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return http.StatusNotFound, errors.New("Access point is not found")
+        }
+        return http.StatusInternalServerError, err
+    }
+    segments := map[string]bool{}
+    for _, segment := range splitPath(accessPoint.EndPoint) {
+        segments[segment] = true
+    }
+    for _, param := range params {
+        if !segments[":"+param] {
+            return http.StatusBadRequest, fmt.Errorf("Endpoint %q has no parameter :%s for the owner condition", accessPoint.EndPoint, param)
+        }
+    }
+
+    return http.StatusOK, nil
+}
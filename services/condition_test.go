@@ -0,0 +1,116 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCondition(t *testing.T) {
+    valid := map[string]string{
+        "":                                     "",
+        "owner":                                "owner",
+        "OWNER(userId)":                        "owner(userId)",
+        "ip in 10.0.0.0/8,192.168.1.7":         "ip in 10.0.0.0/8, 192.168.1.7/32",
+        "hours 9:00-18:00":                     "hours 09:00-18:00",
+        "hours 22:00-06:00 Asia/Singapore":     "hours 22:00-06:00 Asia/Singapore",
+        "owner&&ip in 10.0.0.0/8 && hours 09:00-17:30": "owner && ip in 10.0.0.0/8 && hours 09:00-17:30",
+    }
+    for expr, canonical := range valid {
+        condition, err := ParseCondition(expr)
+        if assert.NoError(t, err, expr) {
+            assert.Equal(t, canonical, condition.String(), expr)
+        }
+    }
+
+    invalid := []string{
+        "admin",
+        "owner &&",
+        "owner(1d)",
+        "ip 10.0.0.0/8",
+        "ip in 10.0.0.0/33",
+        "hours 09:00",
+        "hours 09:00-09:00",
+        "hours 09:00-25:00",
+        "hours 09:00-18:00 Mars/Olympus",
+    }
+    for _, expr := range invalid {
+        _, err := ParseCondition(expr)
+        assert.Error(t, err, expr)
+    }
+}
+
+func TestConditionEvaluate(t *testing.T) {
+    // 10:30 in Singapore, 02:30 UTC
+    now := time.Date(2023, 11, 6, 2, 30, 0, 0, time.UTC)
+
+    tests := []struct {
+        expr   string
+        env    ConditionEnv
+        holds  bool
+        failed string
+    }{
+        {"owner", ConditionEnv{UserId: "42", Params: map[string]string{"id": "42"}}, true, ""},
+        {"owner", ConditionEnv{UserId: "42", Params: map[string]string{"id": "7"}}, false, "owner"},
+        {"owner", ConditionEnv{UserId: "42", Params: map[string]string{}}, false, "owner"},
+        {"ip in 10.0.0.0/8", ConditionEnv{SourceIP: "10.1.2.3"}, true, ""},
+        {"ip in 10.0.0.0/8", ConditionEnv{SourceIP: "10.1.2.3:51234"}, true, ""},
+        {"ip in 10.0.0.0/8", ConditionEnv{SourceIP: "172.16.0.1"}, false, "ip in 10.0.0.0/8"},
+        {"ip in 10.0.0.0/8", ConditionEnv{}, false, "ip in 10.0.0.0/8"},
+        {"hours 09:00-18:00 Asia/Singapore", ConditionEnv{Now: now}, true, ""},
+        {"hours 09:00-18:00", ConditionEnv{Now: now}, false, "hours 09:00-18:00"},
+        {"hours 22:00-06:00", ConditionEnv{Now: now}, true, ""},
+        {"owner && ip in 10.0.0.0/8", ConditionEnv{UserId: "42", Params: map[string]string{"id": "42"}, SourceIP: "192.168.0.1"}, false, "ip in 10.0.0.0/8"},
+    }
+
+    for _, tc := range tests {
+        condition, err := ParseCondition(tc.expr)
+        if !assert.NoError(t, err, tc.expr) {
+            continue
+        }
+        holds, failed := condition.Evaluate(tc.env)
+        assert.Equal(t, tc.holds, holds, tc.expr)
+        assert.Equal(t, tc.failed, failed, tc.expr)
+    }
+}
+
+func TestDecide_Conditions(t *testing.T) {
+    accessService := NewAccessService(gormDB)
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE id = ?")).
+        WithArgs("42").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("42"))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `role_id` FROM `user_roles` WHERE user_id = ?")).
+        WithArgs("42", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(4))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `roles` WHERE id = ?")).
+        WithArgs(4).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(4, "User", nil))
+    mock.ExpectQuery(regexp.QuoteMeta("WHERE role_access.role_id IN (?)")).
+        WithArgs(4).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "method", "endpoint", "role_id", "role_name", "conditions"}).
+            AddRow(3, "Get user", "GET", "/users/accounts/:id", 4, "User", "owner").
+            AddRow(5, "Update user", "PUT", "/users/accounts/:id", 4, "User", "owner && ip in 10.0.0.0/8"))
+
+    res, statusCode, err := accessService.Decide(models.PolicySubject{UserId: "42"}, []models.AuthorizationCheck{
+        {Method: "GET", Path: "/users/accounts/42"},
+        {Method: "GET", Path: "/users/accounts/7"},
+        {Method: "PUT", Path: "/users/accounts/42", SourceIP: "10.0.0.5"},
+        {Method: "PUT", Path: "/users/accounts/42", SourceIP: "203.0.113.9"},
+    })
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.True(t, res.Decisions[0].Allowed)
+    assert.False(t, res.Decisions[1].Allowed)
+    assert.Equal(t, `Condition "owner" is not met`, res.Decisions[1].Reason)
+    assert.True(t, res.Decisions[2].Allowed)
+    assert.False(t, res.Decisions[3].Allowed)
+    assert.Equal(t, `Condition "ip in 10.0.0.0/8" is not met`, res.Decisions[3].Reason)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"user-storage/models"
+
+	"gorm.io/gorm"
+)
+
+// UserScope restricts which users a caller can see. RoleIds are the roles
+// whose holders are visible, and Unassigned makes users without any role
+// visible too.
+type UserScope struct {
+    RoleIds    []int
+    Unassigned bool
+}
+
+type DataScopeService struct {
+    DB *gorm.DB
+}
+
+func NewDataScopeService(db *gorm.DB) *DataScopeService {
+    return &DataScopeService{DB: db}
+}
+
+func (t *DataScopeService) GetAllDataScopes() (*[]models.DataScope, int, error) {
+    var scopes []models.DataScope
+    if err := t.DB.Order("role_id, target_role_id").Find(&scopes).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    return &scopes, http.StatusOK, nil
+}
+
+func (t *DataScopeService) AddDataScope(scope *models.DataScope) (*models.DataScope, int, error) {
+    if err := validate.Struct(scope); err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+
+    roleIds := []int{scope.RoleId}
+    if *scope.TargetRoleId != 0 {
+        roleIds = append(roleIds, *scope.TargetRoleId)
+    }
+    var count int64
+    if err := t.DB.Model(&models.Role{}).Where("id IN ?", roleIds).Count(&count).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    if int(count) != len(roleIds) {
+        return nil, http.StatusBadRequest, errors.New("Role ID is not found")
+    }
+
+    if err := t.DB.Create(scope).Error; err != nil {
+        if errors.Is(err, gorm.ErrDuplicatedKey) {
+            return nil, http.StatusConflict, errors.New("Data scope already exists")
+        }
+        return nil, http.StatusInternalServerError, err
+    }
+    return scope, http.StatusCreated, nil
+}
+
+func (t *DataScopeService) DeleteDataScope(scope *models.DataScope) (*models.DataScope, int, error) {
+    if err := validate.Struct(scope); err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+
+    result := t.DB.Where("role_id = ? AND target_role_id = ?", scope.RoleId, *scope.TargetRoleId).Delete(&models.DataScope{})
+    if result.Error != nil {
+        return nil, http.StatusInternalServerError, result.Error
+    }
+    if result.RowsAffected == 0 {
+        return nil, http.StatusNotFound, errors.New("Data scope is not found")
+    }
+    return scope, http.StatusOK, nil
+}
+
+// ScopeForUser returns the scope of the users a caller can see, or nil when
+// the caller is unrestricted. A caller is only restricted when every role they
+// hold has data scopes, so any unscoped role lifts the restriction.
+func (t *DataScopeService) ScopeForUser(userId string) (*UserScope, int, error) {
+    var roleIds []int
+    err := t.DB.Model(&models.UserRole{}).Scopes(ActiveRoleAssignments).
+        Where("user_id = ?", userId).
+        Pluck("role_id", &roleIds).Error
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    if len(roleIds) == 0 {
+        return nil, http.StatusOK, nil
+    }
+
+    var scopes []models.DataScope
+    if err := t.DB.Where("role_id IN ?", roleIds).Order("role_id, target_role_id").Find(&scopes).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+
+    scoped := map[int]bool{}
+    visible := map[int]bool{}
+    userScope := &UserScope{}
+    for _, scope := range scopes {
+        scoped[scope.RoleId] = true
+        if *scope.TargetRoleId == 0 {
+            userScope.Unassigned = true
+        } else if !visible[*scope.TargetRoleId] {
+            visible[*scope.TargetRoleId] = true
+            userScope.RoleIds = append(userScope.RoleIds, *scope.TargetRoleId)
+        }
+    }
+    for _, roleId := range roleIds {
+        if !scoped[roleId] {
+            return nil, http.StatusOK, nil
+        }
+    }
+
+    return userScope, http.StatusOK, nil
+}
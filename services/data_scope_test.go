@@ -0,0 +1,79 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestScopeForUser(t *testing.T) {
+    dataScopeService := NewDataScopeService(gormDB)
+    plucked := "SELECT `role_id` FROM `user_roles` WHERE user_id = ?"
+    scopes := "SELECT * FROM `data_scopes` WHERE role_id IN (?"
+
+    // Product Manager (5) sees users without a role and Sales (3)
+    mock.ExpectQuery(regexp.QuoteMeta(plucked)).
+        WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(5))
+    mock.ExpectQuery(regexp.QuoteMeta(scopes)).
+        WithArgs(5).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id", "target_role_id"}).AddRow(5, 0).AddRow(5, 3))
+
+    scope, statusCode, err := dataScopeService.ScopeForUser("1")
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Equal(t, &UserScope{RoleIds: []int{3}, Unassigned: true}, scope)
+
+    // Also holding the unscoped Admin (1) role lifts the restriction
+    mock.ExpectQuery(regexp.QuoteMeta(plucked)).
+        WithArgs("2", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(1).AddRow(5))
+    mock.ExpectQuery(regexp.QuoteMeta(scopes)).
+        WithArgs(1, 5).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id", "target_role_id"}).AddRow(5, 0))
+
+    scope, statusCode, err = dataScopeService.ScopeForUser("2")
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Nil(t, scope)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddDataScope_Duplicate(t *testing.T) {
+    dataScopeService := NewDataScopeService(gormDB)
+    target := 3
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `roles` WHERE id IN (?,?)")).
+        WithArgs(5, 3).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+    mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `data_scopes`")).
+        WillReturnError(gorm.ErrDuplicatedKey)
+
+    _, statusCode, err := dataScopeService.AddDataScope(&models.DataScope{RoleId: 5, TargetRoleId: &target})
+
+    assert.EqualError(t, err, "Data scope already exists")
+    assert.Equal(t, http.StatusConflict, statusCode)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserByID_OutOfScope(t *testing.T) {
+    userService := NewUserService(gormDB).WithScope(&UserScope{Unassigned: true})
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE id NOT IN (SELECT user_roles.user_id FROM `user_roles` WHERE")).
+        WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "3").
+        WillReturnRows(sqlmock.NewRows(columns))
+
+    res, statusCode, err := userService.GetUserByID("3")
+
+    assert.Error(t, err)
+    assert.Equal(t, http.StatusNotFound, statusCode)
+    assert.Nil(t, res)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
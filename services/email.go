@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"user-storage/models"
+
+	"golang.org/x/net/idna"
+	"gorm.io/gorm"
+)
+
+// DuplicateEmailError is returned with 409 when another user already has the
+// email. ExistingId is only set when the caller can see that user and their
+// email.
+type DuplicateEmailError struct {
+    ExistingId string
+}
+
+func (e *DuplicateEmailError) Error() string {
+    if e.ExistingId == "" {
+        return "Email is already in use"
+    }
+    return "Email is already used by user " + e.ExistingId
+}
+
+// ExistingUserId returns the id of the user holding the email, if err is a
+// DuplicateEmailError.
+func ExistingUserId(err error) string {
+    var duplicateErr *DuplicateEmailError
+    if errors.As(err, &duplicateErr) {
+        return duplicateErr.ExistingId
+    }
+    return ""
+}
+
+// NormalizeEmail trims and lower-cases email, and converts an international
+// domain to its ASCII form so "Jane@Bücher.de" and "jane@xn--bcher-kva.de" are
+// the same address.
+func NormalizeEmail(email string) (string, error) {
+    email = strings.TrimSpace(email)
+    at := strings.LastIndex(email, "@")
+    if at <= 0 || at == len(email)-1 {
+        return "", errors.New("Email must be of the form local@domain")
+    }
+    domain, err := idna.Lookup.ToASCII(email[at+1:])
+    if err != nil {
+        return "", errors.New("Email domain is invalid: " + err.Error())
+    }
+    return strings.ToLower(email[:at]) + "@" + strings.ToLower(domain), nil
+}
+
+// normalizeUserEmail normalizes the email of user in place, leaving an empty
+// email for validation to report.
+func normalizeUserEmail(user *models.User) error {
+    if user.Email == "" {
+        return nil
+    }
+    email, err := NormalizeEmail(user.Email)
+    if err != nil {
+        return err
+    }
+    user.Email = email
+    return nil
+}
+
+// checkEmailAvailable refuses an email another user than exceptId already
+// has, including soft-deleted users. The holder's id is only reported when the
+// service can see them and their email, so the 409 does not reveal deleted
+// users, users outside the caller's data scope, or whose email is redacted.
+// The unique index on users.email is the final guard against races.
+func (t *UserService) checkEmailAvailable(db *gorm.DB, email, exceptId string) (int, error) {
+    var users []models.User
+    query := db.Unscoped().Select("id").Where("email = ?", email)
+    if exceptId != "" {
+        query = query.Where("id <> ?", exceptId)
+    }
+    if err := query.Limit(1).Find(&users).Error; err != nil {
+        return http.StatusInternalServerError, err
+    }
+    if len(users) == 0 {
+        return http.StatusOK, nil
+    }
+    if t.Redaction["email"] != PIIShow {
+        return http.StatusConflict, &DuplicateEmailError{}
+    }
+
+    var visible []models.User
+    if err := t.visible(db).Select("id").Where("id = ?", users[0].Id).Limit(1).Find(&visible).Error; err != nil {
+        return http.StatusInternalServerError, err
+    }
+    if len(visible) == 0 {
+        return http.StatusConflict, &DuplicateEmailError{}
+    }
+    return http.StatusConflict, &DuplicateEmailError{ExistingId: users[0].Id}
+}
+
+// duplicateEmail reports a unique index violation on email as the
+// DuplicateEmailError of whoever won the race.
+func (t *UserService) duplicateEmail(db *gorm.DB, email, exceptId string) (int, error) {
+    if code, err := t.checkEmailAvailable(db, email, exceptId); err != nil {
+        return code, err
+    }
+    return http.StatusConflict, &DuplicateEmailError{}
+}
+
+// ErrEmailRedacted is returned with 403 when a caller whose redaction hides
+// emails looks a user up by one, which would confirm the address.
+var ErrEmailRedacted = errors.New("Not allowed to look up users by email, which is redacted for you")
+
+// GetUserByEmail looks up the user with exactly email, after normalization.
+func (t *UserService) GetUserByEmail(email string) (*models.User, int, error) {
+    if t.Redaction["email"] != PIIShow {
+        return nil, http.StatusForbidden, ErrEmailRedacted
+    }
+    email, err := NormalizeEmail(email)
+    if err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+
+    var user models.User
+    if err := t.visible(t.DB).First(&user, "email = ?", email).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, http.StatusNotFound, errors.New("User with email is not found")
+        }
+        return nil, http.StatusInternalServerError, err
+    }
+    return &user, http.StatusOK, nil
+}
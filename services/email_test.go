@@ -0,0 +1,99 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+    valid := map[string]string{
+        "  Jane.Doe@Example.COM ": "jane.doe@example.com",
+        "jane@Bücher.de":          "jane@xn--bcher-kva.de",
+        "jane@xn--bcher-kva.de":   "jane@xn--bcher-kva.de",
+        "\"a@b\"@example.com":     "\"a@b\"@example.com",
+    }
+    for email, normalized := range valid {
+        got, err := NormalizeEmail(email)
+        if assert.NoError(t, err, email) {
+            assert.Equal(t, normalized, got, email)
+        }
+    }
+
+    for _, email := range []string{"", "jane", "@example.com", "jane@", "jane@exa mple.com"} {
+        _, err := NormalizeEmail(email)
+        assert.Error(t, err, email)
+    }
+}
+
+func TestAddUser_DuplicateEmail(t *testing.T) {
+    userService := NewUserService(gormDB)
+
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE email = ? LIMIT 1")).
+        WithArgs("marilyn@monroe.com").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("7"))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE id = ? AND `users`.`deleted_at` IS NULL LIMIT 1")).
+        WithArgs("7").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("7"))
+    mock.ExpectRollback()
+
+    res, statusCode, err := userService.AddUser(&models.User{FirstName: "Marilyn", LastName: "Monroe", Email: " Marilyn@Monroe.com"})
+
+    assert.Nil(t, res)
+    assert.Equal(t, http.StatusConflict, statusCode)
+    assert.Equal(t, "7", ExistingUserId(err))
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddUser_DuplicateEmailOfDeletedUser(t *testing.T) {
+    userService := NewUserService(gormDB)
+
+    // The email is held by a soft-deleted user, whose id is not given out
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE email = ? LIMIT 1")).
+        WithArgs("marilyn@monroe.com").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("7"))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE id = ? AND `users`.`deleted_at` IS NULL LIMIT 1")).
+        WithArgs("7").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}))
+    mock.ExpectRollback()
+
+    res, statusCode, err := userService.AddUser(&models.User{FirstName: "Marilyn", LastName: "Monroe", Email: "marilyn@monroe.com"})
+
+    assert.Nil(t, res)
+    assert.Equal(t, http.StatusConflict, statusCode)
+    assert.Empty(t, ExistingUserId(err))
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddUser_DuplicateEmailRedacted(t *testing.T) {
+    userService := NewUserService(gormDB).WithRedaction(Redaction{"email": PIIMask})
+
+    // A caller who only sees masked emails is not told whose email it is
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE email = ? LIMIT 1")).
+        WithArgs("marilyn@monroe.com").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("7"))
+    mock.ExpectRollback()
+
+    _, statusCode, err := userService.AddUser(&models.User{FirstName: "Marilyn", LastName: "Monroe", Email: "marilyn@monroe.com"})
+
+    assert.Equal(t, http.StatusConflict, statusCode)
+    assert.Empty(t, ExistingUserId(err))
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserByEmail_Redacted(t *testing.T) {
+    userService := NewUserService(gormDB).WithRedaction(Redaction{"email": PIIOmit})
+
+    _, statusCode, err := userService.GetUserByEmail("marilyn@monroe.com")
+
+    assert.ErrorIs(t, err, ErrEmailRedacted)
+    assert.Equal(t, http.StatusForbidden, statusCode)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
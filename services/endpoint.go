@@ -0,0 +1,120 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"user-storage/models"
+)
+
+// MethodAny lets an access point match every HTTP method.
+const MethodAny = "ANY"
+
+var accessPointMethods = map[string]bool{
+    MethodAny: true,
+    "GET":     true,
+    "POST":    true,
+    "PUT":     true,
+    "PATCH":   true,
+    "DELETE":  true,
+}
+
+var (
+    literalSegment = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+    paramSegment   = regexp.MustCompile(`^:[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// NormalizeAccessPoint upper-cases the method, defaulting it to ANY, and checks
+// that the endpoint is a valid path pattern.
+func NormalizeAccessPoint(accessPoint *models.AccessPoint) error {
+    accessPoint.Method = strings.ToUpper(strings.TrimSpace(accessPoint.Method))
+    if accessPoint.Method == "" {
+        accessPoint.Method = MethodAny
+    }
+    if !accessPointMethods[accessPoint.Method] {
+        return fmt.Errorf("Unsupported method %q", accessPoint.Method)
+    }
+    return ValidateEndpointPattern(accessPoint.EndPoint)
+}
+
+// ValidateEndpointPattern checks a path pattern such as "/users/accounts/:id".
+// Segments are literals, ":name" parameters matching one segment, or a final
+// "*" matching every remaining segment.
+func ValidateEndpointPattern(pattern string) error {
+    if !strings.HasPrefix(pattern, "/") {
+        return fmt.Errorf("Endpoint %q must start with /", pattern)
+    }
+
+    segments := splitPath(pattern)
+    params := map[string]bool{}
+    for i, segment := range segments {
+        switch {
+        case segment == "*":
+            if i != len(segments)-1 {
+                return fmt.Errorf("Endpoint %q may only use * as the last segment", pattern)
+            }
+        case strings.HasPrefix(segment, ":"):
+            if !paramSegment.MatchString(segment) {
+                return fmt.Errorf("Endpoint %q has an invalid parameter %q", pattern, segment)
+            }
+            if params[segment] {
+                return fmt.Errorf("Endpoint %q repeats parameter %q", pattern, segment)
+            }
+            params[segment] = true
+        case !literalSegment.MatchString(segment):
+            return fmt.Errorf("Endpoint %q has an invalid segment %q", pattern, segment)
+        }
+    }
+    return nil
+}
+
+// MatchAccessPoint reports whether the access point applies to a request with
+// the given method and concrete path.
+func MatchAccessPoint(accessPoint models.AccessPoint, method, path string) bool {
+    _, ok := matchAccessPointParams(accessPoint, method, path)
+    return ok
+}
+
+// matchAccessPointParams is MatchAccessPoint that also returns the values of
+// the endpoint's path parameters.
+func matchAccessPointParams(accessPoint models.AccessPoint, method, path string) (map[string]string, bool) {
+    if accessPoint.Method != "" && accessPoint.Method != MethodAny && !strings.EqualFold(accessPoint.Method, method) {
+        return nil, false
+    }
+    return matchPath(accessPoint.EndPoint, path)
+}
+
+func matchPath(pattern, path string) (map[string]string, bool) {
+    patternSegments := splitPath(pattern)
+    pathSegments := splitPath(path)
+    params := map[string]string{}
+
+    for i, segment := range patternSegments {
+        if segment == "*" {
+            return params, true
+        }
+        if i >= len(pathSegments) {
+            return nil, false
+        }
+        if strings.HasPrefix(segment, ":") {
+            params[segment[1:]] = pathSegments[i]
+            continue
+        }
+        if segment != pathSegments[i] {
+            return nil, false
+        }
+    }
+    if len(patternSegments) != len(pathSegments) {
+        return nil, false
+    }
+    return params, true
+}
+
+// splitPath splits a path into segments, ignoring leading and trailing slashes.
+func splitPath(path string) []string {
+    trimmed := strings.Trim(path, "/")
+    if trimmed == "" {
+        return []string{}
+    }
+    return strings.Split(trimmed, "/")
+}
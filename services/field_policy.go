@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"user-storage/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+    FieldPolicyReject = "reject"
+    FieldPolicyDrop   = "drop"
+
+    ReasonFieldNotWritable = "field_not_writable"
+)
+
+// FieldPolicyError is returned with 403 when an update writes fields the
+// caller may not write.
+type FieldPolicyError struct {
+    Fields []string
+}
+
+func (e *FieldPolicyError) Error() string {
+    return "Not allowed to write " + strings.Join(e.Fields, ", ")
+}
+
+type FieldPolicyService struct {
+    DB   *gorm.DB
+    // Mode is FieldPolicyReject to refuse updates touching disallowed fields,
+    // or FieldPolicyDrop to ignore those fields and apply the rest.
+    Mode string
+}
+
+// NewFieldPolicyService reads the mode from FIELD_POLICY_MODE, defaulting to
+// reject.
+func NewFieldPolicyService(db *gorm.DB) *FieldPolicyService {
+    mode := FieldPolicyReject
+    if strings.EqualFold(os.Getenv("FIELD_POLICY_MODE"), FieldPolicyDrop) {
+        mode = FieldPolicyDrop
+    }
+    return &FieldPolicyService{DB: db, Mode: mode}
+}
+
+func (t *FieldPolicyService) GetAllFieldPolicies() (*[]models.FieldPolicy, int, error) {
+    var policies []models.FieldPolicy
+    if err := t.DB.Order("role_id, field").Find(&policies).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    return &policies, http.StatusOK, nil
+}
+
+func (t *FieldPolicyService) AddFieldPolicy(policy *models.FieldPolicy) (*models.FieldPolicy, int, error) {
+    if err := validate.Struct(policy); err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+
+    var count int64
+    if err := t.DB.Model(&models.Role{}).Where("id = ?", policy.RoleId).Count(&count).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    if count == 0 {
+        return nil, http.StatusBadRequest, errors.New("Role ID is not found")
+    }
+
+    if err := t.DB.Create(policy).Error; err != nil {
+        if errors.Is(err, gorm.ErrDuplicatedKey) {
+            return nil, http.StatusConflict, errors.New("Field policy already exists")
+        }
+        return nil, http.StatusInternalServerError, err
+    }
+    return policy, http.StatusCreated, nil
+}
+
+func (t *FieldPolicyService) DeleteFieldPolicy(policy *models.FieldPolicy) (*models.FieldPolicy, int, error) {
+    if err := validate.Struct(policy); err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+
+    result := t.DB.Where("role_id = ? AND field = ?", policy.RoleId, policy.Field).Delete(&models.FieldPolicy{})
+    if result.Error != nil {
+        return nil, http.StatusInternalServerError, result.Error
+    }
+    if result.RowsAffected == 0 {
+        return nil, http.StatusNotFound, errors.New("Field policy is not found")
+    }
+    return policy, http.StatusOK, nil
+}
+
+// WritableFields returns the user fields the caller may write, or nil when the
+// caller may write every field. As with data scopes, a caller is only
+// restricted when every role they hold has field policies.
+func (t *FieldPolicyService) WritableFields(userId string) (map[string]bool, int, error) {
+    var roleIds []int
+    err := t.DB.Model(&models.UserRole{}).Scopes(ActiveRoleAssignments).
+        Where("user_id = ?", userId).
+        Pluck("role_id", &roleIds).Error
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    if len(roleIds) == 0 {
+        return nil, http.StatusOK, nil
+    }
+
+    var policies []models.FieldPolicy
+    if err := t.DB.Where("role_id IN ?", roleIds).Order("role_id, field").Find(&policies).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+
+    restricted := map[int]bool{}
+    fields := map[string]bool{}
+    for _, policy := range policies {
+        restricted[policy.RoleId] = true
+        fields[policy.Field] = true
+    }
+    for _, roleId := range roleIds {
+        if !restricted[roleId] {
+            return nil, http.StatusOK, nil
+        }
+    }
+
+    return fields, http.StatusOK, nil
+}
+
+// ChangedUserFields lists the fields updated would change on existing, where a
+// nil role clears the role. Emails are compared once normalized.
+func ChangedUserFields(existing, updated *models.User) []string {
+    var fields []string
+    if updated.FirstName != existing.FirstName {
+        fields = append(fields, "firstName")
+    }
+    if updated.LastName != existing.LastName {
+        fields = append(fields, "lastName")
+    }
+    if email, err := NormalizeEmail(updated.Email); err != nil || email != existing.Email {
+        fields = append(fields, "email")
+    }
+    if (updated.Role == nil) != (existing.Role == nil) || (updated.Role != nil && *updated.Role != *existing.Role) {
+        fields = append(fields, "role")
+    }
+    return fields
+}
+
+// CheckUserUpdate applies the caller's field policies to an update of existing
+// into updated, and is shared by every path that writes users. In drop mode the
+// disallowed fields are reset to their existing values in updated and
+// returned; in reject mode they are returned in a FieldPolicyError.
+func (t *FieldPolicyService) CheckUserUpdate(callerId string, existing, updated *models.User) ([]string, int, error) {
+    changed := ChangedUserFields(existing, updated)
+    if len(changed) == 0 {
+        return nil, http.StatusOK, nil
+    }
+
+    writable, code, err := t.WritableFields(callerId)
+    if err != nil {
+        return nil, code, err
+    }
+    if writable == nil {
+        return nil, http.StatusOK, nil
+    }
+
+    var denied []string
+    for _, field := range changed {
+        if !writable[field] {
+            denied = append(denied, field)
+        }
+    }
+    if len(denied) == 0 {
+        return nil, http.StatusOK, nil
+    }
+    if t.Mode != FieldPolicyDrop {
+        return denied, http.StatusForbidden, &FieldPolicyError{Fields: denied}
+    }
+
+    for _, field := range denied {
+        switch field {
+        case "firstName":
+            updated.FirstName = existing.FirstName
+        case "lastName":
+            updated.LastName = existing.LastName
+        case "email":
+            updated.Email = existing.Email
+        case "role":
+            updated.Role = existing.Role
+        }
+    }
+    return denied, http.StatusOK, nil
+}
+
+// CheckRoleChange applies the caller's field policies to assigning or revoking
+// a role, which can set or clear the user's primary role. Either mode refuses
+// it, as dropping the role would leave nothing to apply.
+func (t *FieldPolicyService) CheckRoleChange(callerId string) (int, error) {
+    writable, code, err := t.WritableFields(callerId)
+    if err != nil {
+        return code, err
+    }
+    if writable != nil && !writable["role"] {
+        return http.StatusForbidden, &FieldPolicyError{Fields: []string{"role"}}
+    }
+    return http.StatusOK, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCheckUserUpdate(t *testing.T) {
+    fieldPolicyService := &FieldPolicyService{DB: gormDB, Mode: FieldPolicyReject}
+    plucked := "SELECT `role_id` FROM `user_roles` WHERE user_id = ?"
+    policies := "SELECT * FROM `field_policies` WHERE role_id IN (?"
+    expectAgent := func() {
+        // Agent (6) may only fix names
+        mock.ExpectQuery(regexp.QuoteMeta(plucked)).
+            WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+            WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(6))
+        mock.ExpectQuery(regexp.QuoteMeta(policies)).
+            WithArgs(6).
+            WillReturnRows(sqlmock.NewRows([]string{"role_id", "field"}).AddRow(6, "firstName").AddRow(6, "lastName"))
+    }
+
+    role := uint(2)
+    existing := models.User{Id: "2", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com"}
+
+    expectAgent()
+    updated := existing
+    updated.LastName = "Do"
+    dropped, statusCode, err := fieldPolicyService.CheckUserUpdate("1", &existing, &updated)
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Empty(t, dropped)
+
+    expectAgent()
+    updated = existing
+    updated.LastName = "Do"
+    updated.Email = "someone@example.com"
+    updated.Role = &role
+    dropped, statusCode, err = fieldPolicyService.CheckUserUpdate("1", &existing, &updated)
+    assert.Equal(t, http.StatusForbidden, statusCode)
+    assert.Equal(t, []string{"email", "role"}, dropped)
+    assert.Equal(t, ReasonFieldNotWritable, PrivilegeReason(err))
+
+    fieldPolicyService.Mode = FieldPolicyDrop
+    expectAgent()
+    dropped, statusCode, err = fieldPolicyService.CheckUserUpdate("1", &existing, &updated)
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Equal(t, []string{"email", "role"}, dropped)
+    assert.Equal(t, "Do", updated.LastName)
+    assert.Equal(t, "jane@example.com", updated.Email)
+    assert.Nil(t, updated.Role)
+
+    // Unchanged fields are never checked
+    dropped, statusCode, err = fieldPolicyService.CheckUserUpdate("1", &existing, &existing)
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Empty(t, dropped)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddFieldPolicy_Duplicate(t *testing.T) {
+    fieldPolicyService := &FieldPolicyService{DB: gormDB, Mode: FieldPolicyReject}
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `roles` WHERE id = ?")).
+        WithArgs(6).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+    mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `field_policies`")).
+        WillReturnError(gorm.ErrDuplicatedKey)
+
+    _, statusCode, err := fieldPolicyService.AddFieldPolicy(&models.FieldPolicy{RoleId: 6, Field: "email"})
+
+    assert.Error(t, err)
+    assert.Equal(t, http.StatusConflict, statusCode)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckRoleChange(t *testing.T) {
+    fieldPolicyService := &FieldPolicyService{DB: gormDB, Mode: FieldPolicyDrop}
+
+    // Agent (6) may only fix names, so may not assign or revoke roles even in
+    // drop mode
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `role_id` FROM `user_roles` WHERE user_id = ?")).
+        WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(6))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `field_policies` WHERE role_id IN (?")).
+        WithArgs(6).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id", "field"}).AddRow(6, "firstName").AddRow(6, "lastName"))
+
+    statusCode, err := fieldPolicyService.CheckRoleChange("1")
+
+    assert.Equal(t, http.StatusForbidden, statusCode)
+    assert.Equal(t, ReasonFieldNotWritable, PrivilegeReason(err))
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
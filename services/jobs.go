@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+	"user-storage/models"
+
+	"gorm.io/gorm"
+)
+
+// Maintenance jobs are run on a schedule from outside the service, by
+// EventBridge under Lambda or through POST /jobs/{name} elsewhere, as a
+// frozen or scaled-out process cannot keep its own timers.
+const (
+    JobSweepRoleAssignments = "sweep-role-assignments"
+    JobPurgeDeletedUsers    = "purge-deleted-users"
+    JobReloadPolicies       = "reload-policies"
+)
+
+// RunJob runs the maintenance job called name once, as of now, stamping its
+// writes as "system". Jobs only tidy up rows that are already out of effect or
+// reload what is already on disk, so a run that is late, repeated or overlaps
+// another does no harm.
+func RunJob(ctx context.Context, db *gorm.DB, name string, now time.Time) (*models.JobResult, int, error) {
+    userService := NewUserService(db).WithContext(models.WithActor(ctx, "system"))
+
+    switch name {
+    case JobSweepRoleAssignments:
+        expired, code, err := userService.SweepExpiredRoleAssignments(now)
+        if err != nil {
+            return nil, code, err
+        }
+        return &models.JobResult{Job: name, Affected: len(*expired)}, http.StatusOK, nil
+    case JobPurgeDeletedUsers:
+        purged, code, err := userService.PurgeDeletedUsers(now.Add(-UserRetention()))
+        if err != nil {
+            return nil, code, err
+        }
+        return &models.JobResult{Job: name, Affected: len(*purged)}, http.StatusOK, nil
+    case JobReloadPolicies:
+        // Only reaches the instance the job runs on, others reload on their
+        // next cold start or run
+        engine, ok := DefaultPolicyEngine.(*CELPolicyEngine)
+        if !ok {
+            return &models.JobResult{Job: name}, http.StatusOK, nil
+        }
+        reloaded, err := engine.ReloadIfChanged()
+        if err != nil {
+            return nil, http.StatusInternalServerError, err
+        }
+        if reloaded {
+            return &models.JobResult{Job: name, Affected: len(engine.Policies())}, http.StatusOK, nil
+        }
+        return &models.JobResult{Job: name}, http.StatusOK, nil
+    }
+
+    return nil, http.StatusNotFound, fmt.Errorf("Job %q is not found", name)
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunJob_SweepRoleAssignments(t *testing.T) {
+    now := time.Now()
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `user_roles` WHERE valid_until <= ?")).
+        WithArgs(now).
+        WillReturnRows(sqlmock.NewRows([]string{"user_id", "role_id", "valid_from", "valid_until"}))
+
+    res, statusCode, err := RunJob(context.Background(), gormDB, JobSweepRoleAssignments, now)
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Equal(t, JobSweepRoleAssignments, res.Job)
+    assert.Equal(t, 0, res.Affected)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunJob_PurgeDeletedUsers(t *testing.T) {
+    t.Setenv("USER_RETENTION_DAYS", "7")
+    now := time.Now()
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE deleted_at < ?")).
+        WithArgs(now.Add(-7 * 24 * time.Hour)).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "deleted_at"}))
+
+    res, statusCode, err := RunJob(context.Background(), gormDB, JobPurgeDeletedUsers, now)
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Equal(t, 0, res.Affected)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunJob_Unknown(t *testing.T) {
+    _, statusCode, err := RunJob(context.Background(), gormDB, "defragment", time.Now())
+
+    assert.Error(t, err)
+    assert.Equal(t, http.StatusNotFound, statusCode)
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"user-storage/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserSortKeys maps the sort keys user listings accept to their columns.
+var UserSortKeys = map[string]string{
+    "id":        "id",
+    "firstName": "first_name",
+    "lastName":  "last_name",
+    "email":     "email",
+    "createdAt": "created_at",
+    "updatedAt": "updated_at",
+    "createdBy": "created_by",
+    "updatedBy": "updated_by",
+}
+
+const (
+    CursorNext = "next"
+    CursorPrev = "prev"
+)
+
+// MaxPageSize caps the size of a page, set by PAGE_SIZE_MAX.
+func MaxPageSize() int {
+    if max, err := strconv.Atoi(os.Getenv("PAGE_SIZE_MAX")); err == nil && max > 0 {
+        return max
+    }
+    return 100
+}
+
+// PageRequest asks for the page of Size users after, or before, the row a
+// cursor token was taken from. Without a cursor it asks for the first page.
+type PageRequest struct {
+    Size   int
+    Cursor string
+    Sort   []string
+    Total  bool
+}
+
+// UserPage is a page of users. Next and Prev are opaque tokens for the
+// neighbouring pages, empty at either end. Total is only counted on request.
+type UserPage struct {
+    Users []models.User
+    Next  string
+    Prev  string
+    Total *int64
+}
+
+// cursor is the decoded form of a page token: the sort values and id of the
+// boundary row, and which way to read from it. Sort is kept so a token is only
+// used with the ordering it was made for. Tokens are sealed, so a client can
+// neither read the values nor seek from values of its own.
+type cursor struct {
+    Direction string            `json:"d"`
+    Sort      string            `json:"s"`
+    Values    []json.RawMessage `json:"v"`
+    Id        string            `json:"i"`
+}
+
+var errBadCursor = errors.New("Cursor is invalid or was made for a different sort")
+
+// cursorSealer seals page tokens with a key derived from CURSOR_SECRET. Without
+// one a random key is used, so tokens do not outlive the process or work across
+// replicas.
+var cursorSealer = sync.OnceValue(func() cipher.AEAD {
+    var key [32]byte
+    if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+        key = sha256.Sum256([]byte(secret))
+    } else if _, err := rand.Read(key[:]); err != nil {
+        panic(err)
+    }
+    block, err := aes.NewCipher(key[:])
+    if err != nil {
+        panic(err)
+    }
+    aead, err := cipher.NewGCM(block)
+    if err != nil {
+        panic(err)
+    }
+    return aead
+})
+
+// keysetOrder completes sort with id, so every row has a distinct position.
+func keysetOrder(sort []string) []string {
+    order := make([]string, 0, len(sort)+1)
+    for _, key := range sort {
+        if strings.TrimPrefix(key, "-") == "id" {
+            return append(order, key)
+        }
+        order = append(order, key)
+    }
+    return append(order, "id")
+}
+
+// cursorArg reads the value of a sort key from the boundary user.
+func cursorArg(user models.User, key string) interface{} {
+    switch strings.TrimPrefix(key, "-") {
+    case "firstName":
+        return user.FirstName
+    case "lastName":
+        return user.LastName
+    case "email":
+        return user.Email
+    case "createdAt":
+        return user.CreatedAt
+    case "updatedAt":
+        return user.UpdatedAt
+    case "createdBy":
+        return user.CreatedBy
+    case "updatedBy":
+        return user.UpdatedBy
+    }
+    return user.Id
+}
+
+// cursorValue decodes a sealed sort value back to the type of its column.
+func cursorValue(raw json.RawMessage, key string) (interface{}, error) {
+    switch strings.TrimPrefix(key, "-") {
+    case "createdAt", "updatedAt":
+        var value time.Time
+        err := json.Unmarshal(raw, &value)
+        return value, err
+    }
+    var value string
+    err := json.Unmarshal(raw, &value)
+    return value, err
+}
+
+func encodeCursor(direction string, order []string, user models.User) string {
+    c := cursor{Direction: direction, Sort: strings.Join(order, ","), Id: user.Id}
+    for _, key := range order {
+        value, _ := json.Marshal(cursorArg(user, key))
+        c.Values = append(c.Values, value)
+    }
+    raw, _ := json.Marshal(c)
+    aead := cursorSealer()
+    nonce := make([]byte, aead.NonceSize())
+    if _, err := rand.Read(nonce); err != nil {
+        panic(err)
+    }
+    return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, raw, nil))
+}
+
+// decodeCursor opens token and returns it with its sort values, one per key
+// of order.
+func decodeCursor(token string, order []string) (*cursor, []interface{}, error) {
+    sealed, err := base64.RawURLEncoding.DecodeString(token)
+    aead := cursorSealer()
+    if err != nil || len(sealed) < aead.NonceSize() {
+        return nil, nil, errBadCursor
+    }
+    raw, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+    if err != nil {
+        return nil, nil, errBadCursor
+    }
+    var c cursor
+    if err := json.Unmarshal(raw, &c); err != nil {
+        return nil, nil, errBadCursor
+    }
+    if c.Sort != strings.Join(order, ",") || c.Id == "" || len(c.Values) != len(order) ||
+        (c.Direction != CursorNext && c.Direction != CursorPrev) {
+        return nil, nil, errBadCursor
+    }
+    values := make([]interface{}, len(order))
+    for i, key := range order {
+        if values[i], err = cursorValue(c.Values[i], key); err != nil {
+            return nil, nil, errBadCursor
+        }
+    }
+    return &c, values, nil
+}
+
+// seek limits query to the rows past the boundary values in the direction c
+// reads: (a > ?) OR (a = ? AND b > ?) ..., with < for keys read in descending
+// order.
+func seek(query *gorm.DB, order []string, c *cursor, values []interface{}) *gorm.DB {
+    var or []clause.Expression
+    for i, key := range order {
+        var and []clause.Expression
+        for j := 0; j < i; j++ {
+            and = append(and, clause.Eq{Column: clause.Column{Name: UserSortKeys[strings.TrimPrefix(order[j], "-")]}, Value: values[j]})
+        }
+        column := clause.Column{Name: UserSortKeys[strings.TrimPrefix(key, "-")]}
+        if strings.HasPrefix(key, "-") == (c.Direction == CursorNext) {
+            and = append(and, clause.Lt{Column: column, Value: values[i]})
+        } else {
+            and = append(and, clause.Gt{Column: column, Value: values[i]})
+        }
+        or = append(or, clause.And(and...))
+    }
+    return query.Where(clause.Or(or...))
+}
+
+// GetPaginatedUsers returns a page of the visible users matching filter in a
+// stable keyset order, so pages do not shift as users are added or removed.
+func (t *UserService) GetPaginatedUsers(filter UserFilter, request PageRequest) (*UserPage, int, error) {
+    if request.Size < 1 || request.Size > MaxPageSize() {
+        return nil, http.StatusBadRequest, errors.New("Page size must be between 1 and " + strconv.Itoa(MaxPageSize()))
+    }
+    order := keysetOrder(request.Sort)
+
+    query := t.filtered(filter)
+    var c *cursor
+    if request.Cursor != "" {
+        // The boundary is not read back, so a token still works after its
+        // user is deleted or moves out of the caller's scope
+        var values []interface{}
+        var err error
+        if c, values, err = decodeCursor(request.Cursor, order); err != nil {
+            return nil, http.StatusBadRequest, err
+        }
+        query = seek(query, order, c, values)
+    }
+    // Pages before the cursor are read backwards and turned around below
+    backwards := c != nil && c.Direction == CursorPrev
+    for _, key := range order {
+        query = query.Order(sortClause(key, UserSortKeys, backwards))
+    }
+
+    var users []models.User
+    if err := query.Limit(request.Size + 1).Find(&users).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    more := len(users) > request.Size
+    if more {
+        users = users[:request.Size]
+    }
+    if backwards {
+        for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+            users[i], users[j] = users[j], users[i]
+        }
+    }
+
+    page := &UserPage{Users: users}
+    if len(users) > 0 {
+        first, last := users[0], users[len(users)-1]
+        if (backwards && more) || (!backwards && c != nil) {
+            page.Prev = encodeCursor(CursorPrev, order, first)
+        }
+        if (!backwards && more) || backwards {
+            page.Next = encodeCursor(CursorNext, order, last)
+        }
+    }
+
+    if request.Total {
+        var total int64
+        if err := t.filtered(filter).Model(&models.User{}).Count(&total).Error; err != nil {
+            return nil, http.StatusInternalServerError, err
+        }
+        page.Total = &total
+    }
+
+    return page, http.StatusOK, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"encoding/base64"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPaginatedUsers(t *testing.T) {
+    userService := NewUserService(gormDB)
+    filter := UserFilter{Role: -1}
+    sort := []string{"-createdAt"}
+    day := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+    pageColumns := []string{"id", "first_name", "last_name", "email", "created_at"}
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE `users`.`deleted_at` IS NULL ORDER BY created_at DESC,id LIMIT 3")).
+        WillReturnRows(sqlmock.NewRows(pageColumns).
+            AddRow("3", "John3", "Doe", "john3@example.com", day.Add(2*time.Hour)).
+            AddRow("1", "John1", "Doe", "john1@example.com", day).
+            AddRow("2", "John2", "Doe", "john2@example.com", day))
+
+    first, statusCode, err := userService.GetPaginatedUsers(filter, PageRequest{Size: 2, Sort: sort})
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Len(t, first.Users, 2)
+    assert.Empty(t, first.Prev)
+    assert.NotEmpty(t, first.Next)
+    // Tokens are sealed, so the sort values they carry cannot be read
+    raw, err := base64.RawURLEncoding.DecodeString(first.Next)
+    assert.NoError(t, err)
+    assert.NotContains(t, string(raw), "john1@example.com")
+    assert.NotContains(t, string(raw), "2023")
+
+    // The next page seeks past the last row from the values in the token,
+    // breaking the createdAt tie on id, without reading that row again
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE (`created_at` < ? OR (`created_at` = ? AND `id` > ?)) AND `users`.`deleted_at` IS NULL ORDER BY created_at DESC,id LIMIT 3")).
+        WithArgs(day, day, "1").
+        WillReturnRows(sqlmock.NewRows(pageColumns).
+            AddRow("2", "John2", "Doe", "john2@example.com", day))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `users` WHERE `users`.`deleted_at` IS NULL")).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+    second, statusCode, err := userService.GetPaginatedUsers(filter, PageRequest{Size: 2, Sort: sort, Cursor: first.Next, Total: true})
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Len(t, second.Users, 1)
+    assert.Empty(t, second.Next)
+    assert.NotEmpty(t, second.Prev)
+    assert.Equal(t, int64(3), *second.Total)
+
+    // A token that was tampered with is refused
+    raw[len(raw)-1] ^= 1
+
+    _, statusCode, err = userService.GetPaginatedUsers(filter, PageRequest{Size: 2, Sort: sort, Cursor: base64.RawURLEncoding.EncodeToString(raw)})
+
+    assert.Error(t, err)
+    assert.Equal(t, http.StatusBadRequest, statusCode)
+
+    // A token is refused with a sort it was not made for
+    _, statusCode, err = userService.GetPaginatedUsers(filter, PageRequest{Size: 2, Cursor: first.Next})
+
+    assert.Error(t, err)
+    assert.Equal(t, http.StatusBadRequest, statusCode)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
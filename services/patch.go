@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"user-storage/models"
+
+	jsonpatch "github.com/evanphx/json-patch"
+)
+
+const (
+    MergePatchType = "application/merge-patch+json"
+    JSONPatchType  = "application/json-patch+json"
+)
+
+// PatchUser applies patch to existing and returns the patched user, validated
+// as a whole. contentType picks RFC 6902 JSON Patch for JSONPatchType, and
+// RFC 7396 merge patch for MergePatchType or plain JSON. Explicit nulls clear
+// the role. JSON Patch operations that read a field the caller's redaction
+// hides are refused, as their outcome would reveal it.
+func PatchUser(existing *models.User, patch []byte, contentType string, redaction Redaction) (*models.User, int, error) {
+    doc, err := json.Marshal(existing)
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+
+    var patched []byte
+    switch contentType {
+    case JSONPatchType:
+        operations, err := jsonpatch.DecodePatch(patch)
+        if err != nil {
+            return nil, http.StatusBadRequest, err
+        }
+        if code, err := checkPatchReads(operations, redaction); err != nil {
+            return nil, code, err
+        }
+        if patched, err = operations.Apply(doc); err != nil {
+            if errors.Is(err, jsonpatch.ErrTestFailed) {
+                return nil, http.StatusConflict, err
+            }
+            return nil, http.StatusUnprocessableEntity, err
+        }
+    case MergePatchType, "application/json", "":
+        if patched, err = jsonpatch.MergePatch(doc, patch); err != nil {
+            return nil, http.StatusBadRequest, err
+        }
+    default:
+        return nil, http.StatusUnsupportedMediaType, errors.New("Content-Type must be " + MergePatchType + " or " + JSONPatchType)
+    }
+
+    var user models.User
+    decoder := json.NewDecoder(bytes.NewReader(patched))
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&user); err != nil {
+        return nil, http.StatusUnprocessableEntity, err
+    }
+    if user.Id != existing.Id {
+        return nil, http.StatusBadRequest, errors.New("User ID cannot be changed")
+    }
+    // Stamps are set by the server, whatever the patch did to them
+    user.Stamps = models.Stamps{}
+
+    if err := normalizeUserEmail(&user); err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+    if err := validate.Struct(&user); err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+    return &user, http.StatusOK, nil
+}
+
+// checkPatchReads refuses operations that read a field redaction hides: test
+// compares against its value, and copy and move carry it somewhere it may be
+// shown. The root path reads every field, so it is refused whenever anything
+// is redacted.
+func checkPatchReads(operations jsonpatch.Patch, redaction Redaction) (int, error) {
+    if len(redaction) == 0 {
+        return http.StatusOK, nil
+    }
+    for _, operation := range operations {
+        var path string
+        var err error
+        switch operation.Kind() {
+        case "test":
+            path, err = operation.Path()
+        case "copy", "move":
+            path, err = operation.From()
+        default:
+            continue
+        }
+        if err != nil {
+            return http.StatusBadRequest, err
+        }
+        if path == "" {
+            return http.StatusForbidden, errors.New("Not allowed to " + operation.Kind() + " the whole user, which has fields redacted for you")
+        }
+        field := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+        if redaction[field] != PIIShow {
+            return http.StatusForbidden, errors.New("Not allowed to " + operation.Kind() + " " + field + ", which is redacted for you")
+        }
+    }
+    return http.StatusOK, nil
+}
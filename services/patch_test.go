@@ -0,0 +1,103 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchUser(t *testing.T) {
+    role := uint(2)
+    existing := &models.User{Id: "1", FirstName: "John", LastName: "Doe", Email: "john@example.com", Role: &role}
+
+    user, statusCode, err := PatchUser(existing, []byte(`{"lastName": "Dough", "role": null}`), MergePatchType, nil)
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Equal(t, &models.User{Id: "1", FirstName: "John", LastName: "Dough", Email: "john@example.com"}, user)
+
+    user, statusCode, err = PatchUser(existing, []byte(`[
+        {"op": "test", "path": "/email", "value": "john@example.com"},
+        {"op": "replace", "path": "/email", "value": "John@Example.org"}
+    ]`), JSONPatchType, nil)
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Equal(t, "john@example.org", user.Email)
+    assert.Equal(t, role, *user.Role)
+
+    failures := []struct {
+        patch       string
+        contentType string
+        statusCode  int
+    }{
+        {`{"firstName": null}`, MergePatchType, http.StatusBadRequest},
+        {`{"email": "not-an-email"}`, "application/json", http.StatusBadRequest},
+        {`{"id": "2"}`, MergePatchType, http.StatusBadRequest},
+        {`{"nickname": "Johnny"}`, MergePatchType, http.StatusUnprocessableEntity},
+        {`[{"op": "test", "path": "/lastName", "value": "Smith"}]`, JSONPatchType, http.StatusConflict},
+        {`[{"op": "remove", "path": "/middleName"}]`, JSONPatchType, http.StatusUnprocessableEntity},
+        {`{"op": "remove"}`, JSONPatchType, http.StatusBadRequest},
+        {`lastName=Dough`, "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+    }
+    for _, tc := range failures {
+        _, statusCode, err := PatchUser(existing, []byte(tc.patch), tc.contentType, nil)
+        assert.Error(t, err, tc.patch)
+        assert.Equal(t, tc.statusCode, statusCode, tc.patch)
+    }
+}
+
+func TestPatchUser_RedactedReads(t *testing.T) {
+    existing := &models.User{Id: "1", FirstName: "John", LastName: "Doe", Email: "john@example.com"}
+    redaction := Redaction{"email": PIIMask}
+
+    // Whether a test passes, or what a copy shows, would reveal the email
+    for _, patch := range []string{
+        `[{"op": "test", "path": "/email", "value": "john@example.com"}]`,
+        `[{"op": "copy", "from": "/email", "path": "/firstName"}]`,
+        `[{"op": "move", "from": "/email", "path": "/lastName"}]`,
+        // The root path reads the email along with everything else
+        `[{"op": "test", "path": "", "value": {"id": "1", "firstName": "John", "lastName": "Doe", "email": "john@example.com", "role": null, "version": 0, "deletedAt": null}}]`,
+        `[{"op": "copy", "from": "", "path": "/firstName"}]`,
+    } {
+        _, statusCode, err := PatchUser(existing, []byte(patch), JSONPatchType, redaction)
+        assert.Error(t, err, patch)
+        assert.Equal(t, http.StatusForbidden, statusCode, patch)
+    }
+
+    user, statusCode, err := PatchUser(existing, []byte(`[
+        {"op": "test", "path": "/lastName", "value": "Doe"},
+        {"op": "replace", "path": "/email", "value": "john@example.org"}
+    ]`), JSONPatchType, redaction)
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Equal(t, "john@example.org", user.Email)
+}
+
+func TestReplaceUserById_ClearsRole(t *testing.T) {
+    userService := NewUserService(gormDB)
+    id := "1"
+
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE id = ?")).
+        WithArgs(id).
+        WillReturnRows(sqlmock.NewRows(append(columns, "version")).AddRow(id, "John1", "Doe", "john1@example.com", 2, 3))
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `first_name`=?,`last_name`=?,`email`=?,`role`=?,`version`=?,`updated_at`=?,`updated_by`=? WHERE version = ? AND `users`.`deleted_at` IS NULL AND `id` = ?")).
+        WithArgs("John1", "Dough", "john1@example.com", nil, 4, sqlmock.AnyArg(), "", 3, id).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `user_roles` WHERE user_id = ? AND role_id = ?")).
+        WithArgs(id, 2).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
+
+    user := models.User{FirstName: "John1", LastName: "Dough", Email: "john1@example.com"}
+    res, statusCode, err := userService.ReplaceUserById(&user, id)
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Nil(t, res.Role)
+    assert.Equal(t, 4, res.Version)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+	"user-storage/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+    PIIShow = ""
+    PIIMask = "mask"
+    PIIOmit = "omit"
+
+    PIIName  = "name"
+    PIIEmail = "email"
+
+    ReasonRedactedField = "redacted_field"
+)
+
+// PIIFields classifies the user fields holding personal data by how they are
+// masked.
+var PIIFields = map[string]string{
+    "firstName": PIIName,
+    "lastName":  PIIName,
+    "email":     PIIEmail,
+}
+
+// MaskName keeps the first letter of name, "Jane" becoming "J***".
+func MaskName(name string) string {
+    if name == "" {
+        return ""
+    }
+    first, _ := utf8.DecodeRuneInString(name)
+    return string(first) + "***"
+}
+
+// MaskEmail keeps the first letter of the local part and the domain,
+// "jane@example.com" becoming "j***@example.com".
+func MaskEmail(email string) string {
+    at := strings.LastIndex(email, "@")
+    if at < 0 {
+        return MaskName(email)
+    }
+    return MaskName(email[:at]) + email[at:]
+}
+
+// Redaction maps user fields to PIIMask or PIIOmit. A nil Redaction returns
+// users in full.
+type Redaction map[string]string
+
+func redactField(value, class, action string) string {
+    switch action {
+    case PIIOmit:
+        return ""
+    case PIIMask:
+        if class == PIIEmail {
+            return MaskEmail(value)
+        }
+        return MaskName(value)
+    }
+    return value
+}
+
+// User returns a copy of user with the redaction applied. Omitted fields are
+// left empty.
+func (r Redaction) User(user models.User) models.User {
+    if len(r) == 0 {
+        return user
+    }
+    user.FirstName = redactField(user.FirstName, PIIFields["firstName"], r["firstName"])
+    user.LastName = redactField(user.LastName, PIIFields["lastName"], r["lastName"])
+    user.Email = redactField(user.Email, PIIFields["email"], r["email"])
+    return user
+}
+
+func (r Redaction) Users(users []models.User) []models.User {
+    redacted := make([]models.User, len(users))
+    for i, user := range users {
+        redacted[i] = r.User(user)
+    }
+    return redacted
+}
+
+// piiValues lists the PII fields of user in a fixed order.
+func piiValues(user *models.User) [][2]string {
+    return [][2]string{
+        {"firstName", user.FirstName},
+        {"lastName", user.LastName},
+        {"email", user.Email},
+    }
+}
+
+// MaskedFields lists the fields updated would change on existing to exactly
+// what the redaction shows of them. Those are redacted values read back and
+// written, which would otherwise overwrite the real ones with "J***".
+func (r Redaction) MaskedFields(existing, updated *models.User) []string {
+    var fields []string
+    current := piiValues(existing)
+    for i, value := range piiValues(updated) {
+        field, action := value[0], r[value[0]]
+        if action == PIIShow || value[1] == current[i][1] {
+            continue
+        }
+        if value[1] == redactField(current[i][1], PIIFields[field], action) {
+            fields = append(fields, field)
+        }
+    }
+    return fields
+}
+
+type PIIService struct {
+    DB *gorm.DB
+}
+
+func NewPIIService(db *gorm.DB) *PIIService {
+    return &PIIService{DB: db}
+}
+
+func (t *PIIService) GetAllPIIPolicies() (*[]models.PIIPolicy, int, error) {
+    var policies []models.PIIPolicy
+    if err := t.DB.Order("role_id, field").Find(&policies).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    return &policies, http.StatusOK, nil
+}
+
+// AddPIIPolicy creates the policy, or changes the action of an existing policy
+// for the same role and field.
+func (t *PIIService) AddPIIPolicy(policy *models.PIIPolicy) (*models.PIIPolicy, int, error) {
+    if err := validate.Struct(policy); err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+
+    var count int64
+    if err := t.DB.Model(&models.Role{}).Where("id = ?", policy.RoleId).Count(&count).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    if count == 0 {
+        return nil, http.StatusBadRequest, errors.New("Role ID is not found")
+    }
+
+    if err := t.DB.Save(policy).Error; err != nil {
+        // Save updates first and inserts when nothing matched, so a concurrent
+        // insert of the same policy can still collide
+        if errors.Is(err, gorm.ErrDuplicatedKey) {
+            return nil, http.StatusConflict, errors.New("PII policy already exists")
+        }
+        return nil, http.StatusInternalServerError, err
+    }
+    return policy, http.StatusCreated, nil
+}
+
+func (t *PIIService) DeletePIIPolicy(policy *models.PIIPolicy) (*models.PIIPolicy, int, error) {
+    if policy.RoleId == 0 || policy.Field == "" {
+        return nil, http.StatusBadRequest, errors.New("roleId and field are required")
+    }
+
+    result := t.DB.Where("role_id = ? AND field = ?", policy.RoleId, policy.Field).Delete(&models.PIIPolicy{})
+    if result.Error != nil {
+        return nil, http.StatusInternalServerError, result.Error
+    }
+    if result.RowsAffected == 0 {
+        return nil, http.StatusNotFound, errors.New("PII policy is not found")
+    }
+    return policy, http.StatusOK, nil
+}
+
+// RedactionForUser returns how PII is shaped for the caller. Each field gets
+// the least restrictive action among the caller's roles, so a role without a
+// policy for a field shows it in full.
+func (t *PIIService) RedactionForUser(userId string) (Redaction, int, error) {
+    var roleIds []int
+    err := t.DB.Model(&models.UserRole{}).Scopes(ActiveRoleAssignments).
+        Where("user_id = ?", userId).
+        Pluck("role_id", &roleIds).Error
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    if len(roleIds) == 0 {
+        return nil, http.StatusOK, nil
+    }
+
+    var policies []models.PIIPolicy
+    if err := t.DB.Where("role_id IN ?", roleIds).Order("role_id, field").Find(&policies).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+
+    actions := map[string]map[int]string{}
+    for _, policy := range policies {
+        if actions[policy.Field] == nil {
+            actions[policy.Field] = map[int]string{}
+        }
+        actions[policy.Field][policy.RoleId] = policy.Action
+    }
+
+    var redaction Redaction
+    for field, byRole := range actions {
+        action := PIIOmit
+        for _, roleId := range roleIds {
+            switch byRole[roleId] {
+            case PIIShow:
+                action = PIIShow
+            case PIIMask:
+                if action == PIIOmit {
+                    action = PIIMask
+                }
+            }
+            if action == PIIShow {
+                break
+            }
+        }
+        if action != PIIShow {
+            if redaction == nil {
+                redaction = Redaction{}
+            }
+            redaction[field] = action
+        }
+    }
+    return redaction, http.StatusOK, nil
+}
+
+// FilteredFields lists the fields filter selects or sorts users by that the
+// redaction hides. Which users match, and in which order, would reveal them
+// one guess at a time.
+func (r Redaction) FilteredFields(filter UserFilter) []string {
+    if len(r) == 0 {
+        return nil
+    }
+    var names []string
+    if filter.Name != "" {
+        names = append(names, "firstName", "lastName")
+    }
+    if filter.Email != "" {
+        names = append(names, "email")
+    }
+    if filter.Expression != nil {
+        names = filter.Expression.attributes(names)
+    }
+    for _, key := range filter.Stamps.Sort {
+        names = append(names, strings.TrimPrefix(key, "-"))
+    }
+
+    var fields []string
+    seen := map[string]bool{}
+    for _, name := range names {
+        if r[name] != PIIShow && !seen[name] {
+            seen[name] = true
+            fields = append(fields, name)
+        }
+    }
+    return fields
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedaction(t *testing.T) {
+    user := models.User{Id: "1", FirstName: "Élodie", LastName: "Tan", Email: "jane.doe@example.com"}
+
+    assert.Equal(t, user, Redaction(nil).User(user))
+    assert.Equal(t,
+        models.User{Id: "1", FirstName: "É***", LastName: "T***", Email: "j***@example.com"},
+        Redaction{"firstName": PIIMask, "lastName": PIIMask, "email": PIIMask}.User(user))
+    assert.Equal(t,
+        models.User{Id: "1", FirstName: "Élodie", LastName: "Tan", Email: "j***@example.com"},
+        Redaction{"email": PIIMask}.User(user))
+    assert.Equal(t,
+        []models.User{{Id: "1", Email: "jane.doe@example.com"}},
+        Redaction{"firstName": PIIOmit, "lastName": PIIOmit}.Users([]models.User{user}))
+}
+
+func TestMaskedFields(t *testing.T) {
+    existing := models.User{Id: "1", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com"}
+    redaction := Redaction{"firstName": PIIMask, "email": PIIMask, "lastName": PIIOmit}
+
+    // A masked user read back and written whole
+    updated := redaction.User(existing)
+    assert.Equal(t, []string{"firstName", "lastName", "email"}, redaction.MaskedFields(&existing, &updated))
+
+    // Unchanged and genuinely new values are written as usual
+    updated = existing
+    updated.FirstName = "Janet"
+    assert.Empty(t, redaction.MaskedFields(&existing, &updated))
+    assert.Empty(t, Redaction(nil).MaskedFields(&existing, &updated))
+}
+
+func TestFilteredFields(t *testing.T) {
+    expression, err := ParseFilter(`id sw "1" or not email ew "@bank.com"`)
+    assert.NoError(t, err)
+    filter := UserFilter{Role: -1, Name: "Ja", Expression: expression, Stamps: StampFilter{Sort: []string{"-lastName", "createdAt"}}}
+
+    assert.Equal(t, []string{"firstName", "lastName", "email"}, Redaction{"firstName": PIIMask, "lastName": PIIOmit, "email": PIIMask}.FilteredFields(filter))
+    assert.Equal(t, []string{"email"}, Redaction{"email": PIIOmit}.FilteredFields(UserFilter{Role: -1, Expression: expression}))
+    assert.Empty(t, Redaction{"email": PIIMask}.FilteredFields(UserFilter{Role: -1, Id: "1"}))
+    assert.Empty(t, Redaction(nil).FilteredFields(filter))
+}
+
+func TestRedactionForUser(t *testing.T) {
+    piiService := NewPIIService(gormDB)
+
+    // Support (6) sees masked emails and no last names, Sales (3) sees masked
+    // emails and omitted first names
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `role_id` FROM `user_roles` WHERE user_id = ?")).
+        WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(3).AddRow(6))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `pii_policies` WHERE role_id IN (?,?)")).
+        WithArgs(3, 6).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id", "field", "action"}).
+            AddRow(3, "email", "omit").
+            AddRow(3, "firstName", "omit").
+            AddRow(6, "email", "mask").
+            AddRow(6, "lastName", "omit"))
+
+    redaction, statusCode, err := piiService.RedactionForUser("1")
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Equal(t, Redaction{"email": PIIMask}, redaction)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"user-storage/models"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+    PolicyAllow = "allow"
+    PolicyDeny  = "deny"
+    PolicyNone  = "none"
+)
+
+// PolicyEngine decides on requests in addition to role_access. A deny from
+// the engine overrides any grant, and an allow admits a request no grant
+// covers.
+type PolicyEngine interface {
+    Evaluate(input models.PolicyInput) (*models.PolicyResult, error)
+}
+
+// DefaultPolicyEngine is used by every AccessService created with
+// NewAccessService. It is nil unless POLICY_DIR is configured.
+var DefaultPolicyEngine PolicyEngine
+
+// Policy is a rule loaded from a YAML file such as:
+//
+//	name: deny-self-role-change
+//	effect: deny
+//	reason: Users cannot change their own roles
+//	match:
+//	  methods: [POST, DELETE]
+//	  paths: [/users/accounts/:id/roles, /users/accounts/:id/roles/:roleId]
+//	condition: request.params.id == subject.user_id
+//	tests:
+//	  - name: own account
+//	    input: {subject: {user_id: "42"}, request: {method: POST, path: /users/accounts/42/roles}}
+//	    expect: deny
+//
+// The condition is a CEL expression over subject, request and resource, see
+// models.PolicyInput. request.params holds the parameters of the matched path.
+type Policy struct {
+    Name        string          `yaml:"name"`
+    Description string          `yaml:"description"`
+    Effect      string          `yaml:"effect"`
+    Reason      string          `yaml:"reason"`
+    Match       PolicyMatch     `yaml:"match"`
+    Condition   string          `yaml:"condition"`
+    Tests       []PolicyTest    `yaml:"tests"`
+
+    file    string
+    program cel.Program
+}
+
+// PolicyMatch limits a policy to some methods and endpoint patterns. Empty
+// lists match everything.
+type PolicyMatch struct {
+    Methods []string `yaml:"methods"`
+    Paths   []string `yaml:"paths"`
+}
+
+// PolicyTest is a case shipped with a policy. Expect is allow or deny when the
+// policy should fire for the input, or none when it should not.
+type PolicyTest struct {
+    Name    string                  `yaml:"name"`
+    Input   map[string]interface{}  `yaml:"input"`
+    Expect  string                  `yaml:"expect"`
+}
+
+// PolicyTestResult is the outcome of one PolicyTest.
+type PolicyTestResult struct {
+    Policy  string
+    File    string
+    Test    string
+    Expect  string
+    Got     string
+    Err     error
+}
+
+func (r PolicyTestResult) Passed() bool {
+    return r.Err == nil && r.Expect == r.Got
+}
+
+// CELPolicyEngine evaluates policies loaded from the YAML files in a
+// directory. ReloadIfChanged picks up changes to it.
+type CELPolicyEngine struct {
+    dir string
+
+    mu          sync.RWMutex
+    policies    []*Policy
+    fingerprint string
+}
+
+var policyEnv = sync.OnceValues(func() (*cel.Env, error) {
+    return cel.NewEnv(
+        cel.Variable("subject", cel.MapType(cel.StringType, cel.DynType)),
+        cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+        cel.Variable("resource", cel.MapType(cel.StringType, cel.DynType)),
+        ext.Strings(),
+    )
+})
+
+// NewCELPolicyEngine loads every *.yaml and *.yml file in dir.
+func NewCELPolicyEngine(dir string) (*CELPolicyEngine, error) {
+    engine := &CELPolicyEngine{dir: dir}
+    if err := engine.Reload(); err != nil {
+        return nil, err
+    }
+    return engine, nil
+}
+
+// PolicyEngineFromEnv loads policies from POLICY_DIR, or returns nil when it
+// is not set. They are loaded again on every cold start, and in between by the
+// reload-policies job.
+func PolicyEngineFromEnv() (PolicyEngine, error) {
+    dir := os.Getenv("POLICY_DIR")
+    if dir == "" {
+        return nil, nil
+    }
+    return NewCELPolicyEngine(dir)
+}
+
+// Policies returns the currently loaded policies.
+func (e *CELPolicyEngine) Policies() []*Policy {
+    e.mu.RLock()
+    defer e.mu.RUnlock()
+    return e.policies
+}
+
+// Reload compiles the policy directory again. On error the previously loaded
+// policies stay in effect.
+func (e *CELPolicyEngine) Reload() error {
+    fingerprint, files, err := policyFiles(e.dir)
+    if err != nil {
+        return err
+    }
+
+    policies := []*Policy{}
+    names := map[string]string{}
+    for _, file := range files {
+        policy, err := loadPolicy(file)
+        if err != nil {
+            return err
+        }
+        if other, ok := names[policy.Name]; ok {
+            return fmt.Errorf("Policy %q is defined in both %s and %s", policy.Name, other, file)
+        }
+        names[policy.Name] = file
+        policies = append(policies, policy)
+    }
+
+    e.mu.Lock()
+    e.policies = policies
+    e.fingerprint = fingerprint
+    e.mu.Unlock()
+    return nil
+}
+
+// ReloadIfChanged reloads the policy directory when a file was added, removed
+// or modified since the last load, reporting whether it did.
+func (e *CELPolicyEngine) ReloadIfChanged() (bool, error) {
+    fingerprint, _, err := policyFiles(e.dir)
+    if err != nil {
+        return false, err
+    }
+    e.mu.RLock()
+    changed := fingerprint != e.fingerprint
+    e.mu.RUnlock()
+    if !changed {
+        return false, nil
+    }
+    if err := e.Reload(); err != nil {
+        return false, err
+    }
+    log.WithField("DIR", e.dir).Info("POLICIES RELOADED")
+    return true, nil
+}
+
+// Evaluate runs every applicable policy. Any deny wins over allows. A policy
+// whose condition fails to evaluate counts as firing if it denies and as not
+// applying if it allows, so errors never widen access.
+func (e *CELPolicyEngine) Evaluate(input models.PolicyInput) (*models.PolicyResult, error) {
+    var allow *models.PolicyResult
+    for _, policy := range e.Policies() {
+        effect, err := policy.evaluate(input)
+        if err != nil {
+            if policy.Effect != PolicyDeny {
+                continue
+            }
+            return &models.PolicyResult{Effect: PolicyDeny, Policy: policy.Name, Reason: fmt.Sprintf("Policy %q failed to evaluate", policy.Name)}, nil
+        }
+        switch effect {
+        case PolicyDeny:
+            return &models.PolicyResult{Effect: PolicyDeny, Policy: policy.Name, Reason: policy.reason()}, nil
+        case PolicyAllow:
+            if allow == nil {
+                allow = &models.PolicyResult{Effect: PolicyAllow, Policy: policy.Name, Reason: policy.reason()}
+            }
+        }
+    }
+    if allow != nil {
+        return allow, nil
+    }
+    return &models.PolicyResult{}, nil
+}
+
+// RunTests evaluates the test cases shipped with every loaded policy.
+func (e *CELPolicyEngine) RunTests() []PolicyTestResult {
+    var results []PolicyTestResult
+    for _, policy := range e.Policies() {
+        for _, test := range policy.Tests {
+            result := PolicyTestResult{Policy: policy.Name, File: policy.file, Test: test.Name, Expect: test.Expect}
+
+            var input models.PolicyInput
+            raw, err := json.Marshal(test.Input)
+            if err == nil {
+                err = json.Unmarshal(raw, &input)
+            }
+            if err != nil {
+                result.Err = fmt.Errorf("invalid input: %v", err)
+                results = append(results, result)
+                continue
+            }
+
+            effect, err := policy.evaluate(input)
+            result.Got, result.Err = effect, err
+            if result.Got == "" {
+                result.Got = PolicyNone
+            }
+            results = append(results, result)
+        }
+    }
+    return results
+}
+
+// evaluate returns the policy's effect if it applies to the input, or an
+// empty string if it does not.
+func (p *Policy) evaluate(input models.PolicyInput) (string, error) {
+    params, ok := p.match(input.Request.Method, input.Request.Path)
+    if !ok {
+        return "", nil
+    }
+    for name, value := range input.Request.Params {
+        if _, set := params[name]; !set {
+            params[name] = value
+        }
+    }
+    input.Request.Params = params
+    if input.Resource == nil {
+        input.Resource = map[string]interface{}{}
+    }
+
+    activation := map[string]interface{}{}
+    for name, value := range map[string]interface{}{"subject": input.Subject, "request": input.Request, "resource": input.Resource} {
+        raw, err := json.Marshal(value)
+        if err != nil {
+            return "", err
+        }
+        var decoded map[string]interface{}
+        if err := json.Unmarshal(raw, &decoded); err != nil {
+            return "", err
+        }
+        activation[name] = decoded
+    }
+
+    out, _, err := p.program.Eval(activation)
+    if err != nil {
+        return "", fmt.Errorf("Policy %q: %v", p.Name, err)
+    }
+    if fired, _ := out.Value().(bool); fired {
+        return p.Effect, nil
+    }
+    return "", nil
+}
+
+func (p *Policy) match(method, path string) (map[string]string, bool) {
+    if len(p.Match.Methods) > 0 {
+        matched := false
+        for _, m := range p.Match.Methods {
+            if strings.EqualFold(m, method) || strings.EqualFold(m, MethodAny) {
+                matched = true
+                break
+            }
+        }
+        if !matched {
+            return nil, false
+        }
+    }
+    if len(p.Match.Paths) == 0 {
+        return map[string]string{}, true
+    }
+    for _, pattern := range p.Match.Paths {
+        if params, ok := matchPath(pattern, path); ok {
+            return params, true
+        }
+    }
+    return nil, false
+}
+
+func (p *Policy) reason() string {
+    if p.Reason != "" {
+        return p.Reason
+    }
+    return fmt.Sprintf("Policy %q applies", p.Name)
+}
+
+func loadPolicy(file string) (*Policy, error) {
+    raw, err := os.ReadFile(file)
+    if err != nil {
+        return nil, err
+    }
+
+    policy := &Policy{file: file}
+    if err := yaml.Unmarshal(raw, policy); err != nil {
+        return nil, fmt.Errorf("%s: %v", file, err)
+    }
+    if policy.Name == "" {
+        policy.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+    }
+    policy.Effect = strings.ToLower(policy.Effect)
+    if policy.Effect != PolicyAllow && policy.Effect != PolicyDeny {
+        return nil, fmt.Errorf("%s: effect must be allow or deny", file)
+    }
+    if strings.TrimSpace(policy.Condition) == "" {
+        return nil, fmt.Errorf("%s: condition is required", file)
+    }
+    for _, pattern := range policy.Match.Paths {
+        if err := ValidateEndpointPattern(pattern); err != nil {
+            return nil, fmt.Errorf("%s: %v", file, err)
+        }
+    }
+    for _, test := range policy.Tests {
+        if test.Expect != PolicyAllow && test.Expect != PolicyDeny && test.Expect != PolicyNone {
+            return nil, fmt.Errorf("%s: test %q must expect allow, deny or none", file, test.Name)
+        }
+    }
+
+    env, err := policyEnv()
+    if err != nil {
+        return nil, err
+    }
+    ast, issues := env.Compile(policy.Condition)
+    if issues != nil && issues.Err() != nil {
+        return nil, fmt.Errorf("%s: %v", file, issues.Err())
+    }
+    if ast.OutputType() != cel.BoolType {
+        return nil, fmt.Errorf("%s: condition must be a bool, not %v", file, ast.OutputType())
+    }
+    if policy.program, err = env.Program(ast); err != nil {
+        return nil, fmt.Errorf("%s: %v", file, err)
+    }
+
+    return policy, nil
+}
+
+// policyFiles lists the policy files in dir, sorted by name, together with a
+// fingerprint that changes whenever one of them does.
+func policyFiles(dir string) (string, []string, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return "", nil, err
+    }
+
+    var files []string
+    var fingerprint strings.Builder
+    for _, entry := range entries {
+        ext := filepath.Ext(entry.Name())
+        if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+            continue
+        }
+        info, err := entry.Info()
+        if err != nil {
+            if errors.Is(err, os.ErrNotExist) {
+                continue
+            }
+            return "", nil, err
+        }
+        files = append(files, filepath.Join(dir, entry.Name()))
+        fmt.Fprintf(&fingerprint, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+    }
+    sort.Strings(files)
+
+    return fingerprint.String(), files, nil
+}
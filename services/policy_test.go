@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPolicies runs the tests shipped with the policies in /policies.
+func TestPolicies(t *testing.T) {
+    engine, err := NewCELPolicyEngine(filepath.Join("..", "policies"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for _, result := range engine.RunTests() {
+        result := result
+        t.Run(result.Policy+"/"+result.Test, func(t *testing.T) {
+            assert.NoError(t, result.Err, result.File)
+            assert.Equal(t, result.Expect, result.Got, result.File)
+        })
+    }
+}
+
+func writePolicy(t *testing.T, dir, name, content string) {
+    if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestCELPolicyEngine_Evaluate(t *testing.T) {
+    dir := t.TempDir()
+    writePolicy(t, dir, "allow-managers.yaml", `
+effect: allow
+match: {methods: [GET], paths: [/users/accounts/:id]}
+condition: "'Manager' in subject.roles"
+`)
+    writePolicy(t, dir, "deny-other-teams.yaml", `
+effect: deny
+reason: Managers only see their own team
+condition: has(resource.team) && resource.team != subject.role
+`)
+
+    engine, err := NewCELPolicyEngine(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    input := models.PolicyInput{
+        Subject: models.PolicySubject{UserId: "1", Role: "sales", Roles: []string{"Manager"}},
+        Request: models.PolicyRequest{Method: "GET", Path: "/users/accounts/42"},
+    }
+    result, err := engine.Evaluate(input)
+    assert.NoError(t, err)
+    assert.Equal(t, PolicyAllow, result.Effect)
+    assert.Equal(t, "allow-managers", result.Policy)
+
+    input.Resource = map[string]interface{}{"team": "engineering"}
+    result, err = engine.Evaluate(input)
+    assert.NoError(t, err)
+    assert.Equal(t, PolicyDeny, result.Effect)
+    assert.Equal(t, "Managers only see their own team", result.Reason)
+
+    input.Request.Method = "DELETE"
+    input.Resource = nil
+    result, err = engine.Evaluate(input)
+    assert.NoError(t, err)
+    assert.Equal(t, "", result.Effect)
+}
+
+func TestCELPolicyEngine_ReloadIfChanged(t *testing.T) {
+    dir := t.TempDir()
+    writePolicy(t, dir, "allow-all.yaml", "effect: allow\ncondition: 'true'\n")
+
+    engine, err := NewCELPolicyEngine(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    reloaded, err := engine.ReloadIfChanged()
+    assert.NoError(t, err)
+    assert.False(t, reloaded)
+
+    // A broken policy is rejected and the previous set stays in effect
+    writePolicy(t, dir, "broken.yaml", "effect: deny\ncondition: 'subject.user_id +'\n")
+    _, err = engine.ReloadIfChanged()
+    assert.Error(t, err)
+    assert.Len(t, engine.Policies(), 1)
+
+    writePolicy(t, dir, "broken.yaml", "effect: deny\ncondition: subject.user_id == '7'\n")
+    previous := DefaultPolicyEngine
+    DefaultPolicyEngine = engine
+    defer func() { DefaultPolicyEngine = previous }()
+    res, statusCode, err := RunJob(context.Background(), gormDB, JobReloadPolicies, time.Now())
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Equal(t, 2, res.Affected)
+
+    result, err := engine.Evaluate(models.PolicyInput{Subject: models.PolicySubject{UserId: "7"}})
+    assert.NoError(t, err)
+    assert.Equal(t, PolicyDeny, result.Effect)
+}
+
+type stubPolicyEngine struct {
+    result models.PolicyResult
+    input  models.PolicyInput
+}
+
+func (s *stubPolicyEngine) Evaluate(input models.PolicyInput) (*models.PolicyResult, error) {
+    s.input = input
+    return &s.result, nil
+}
+
+func TestDecide_PolicyDenyOverridesGrant(t *testing.T) {
+    policies := &stubPolicyEngine{result: models.PolicyResult{Effect: PolicyDeny, Policy: "deny-self-role-change", Reason: "Users cannot change their own roles"}}
+    accessService := &AccessService{DB: gormDB, Policies: policies}
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE id = ?")).
+        WithArgs("1").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `role_id` FROM `user_roles` WHERE user_id = ?")).
+        WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(2))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `roles` WHERE id = ?")).
+        WithArgs(2).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(2, "Admin", nil))
+    mock.ExpectQuery(regexp.QuoteMeta("WHERE role_access.role_id IN (?)")).
+        WithArgs(2).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "method", "endpoint", "role_id", "role_name", "conditions"}).
+            AddRow(7, "Assign role", "POST", "/users/accounts/:id/roles", 2, "Admin", ""))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `name` FROM `roles` WHERE id IN (?)")).
+        WithArgs(2).
+        WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Admin"))
+
+    res, statusCode, err := accessService.Decide(models.PolicySubject{UserId: "1"}, []models.AuthorizationCheck{
+        {Method: "POST", Path: "/users/accounts/1/roles"},
+    })
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.False(t, res.Decisions[0].Allowed)
+    assert.Nil(t, res.Decisions[0].AccessPoint)
+    assert.Equal(t, "deny-self-role-change", res.Decisions[0].Policy)
+    assert.Equal(t, []string{"Admin"}, policies.input.Subject.Roles)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
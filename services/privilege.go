@@ -0,0 +1,212 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"user-storage/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+    ReasonSelfDelete          = "self_delete"
+    ReasonSelfRoleChange      = "self_role_change"
+    ReasonRankExceeded        = "rank_exceeded"
+    ReasonSuperAdminRequired  = "super_admin_required"
+    ReasonUnranked            = "unranked"
+)
+
+// PrivilegeError is returned with 403 when a mutation would escalate the
+// caller's privileges. Reason is a stable code for clients.
+type PrivilegeError struct {
+    Reason  string
+    Message string
+}
+
+func (e *PrivilegeError) Error() string {
+    return e.Message
+}
+
+// SuperAdminRoles returns the role names allowed to manage admin access
+// points, read from the comma separated SUPER_ADMIN_ROLES and defaulting to
+// "Super Admin".
+func SuperAdminRoles() []string {
+    var roles []string
+    for _, name := range strings.Split(os.Getenv("SUPER_ADMIN_ROLES"), ",") {
+        if name = strings.TrimSpace(name); name != "" {
+            roles = append(roles, name)
+        }
+    }
+    if len(roles) == 0 {
+        roles = []string{"Super Admin"}
+    }
+    return roles
+}
+
+// Privileges is what a user may hand out: roles up to Rank, and admin access
+// points if SuperAdmin. Rank 0 means none of the user's roles is ranked.
+type Privileges struct {
+    Rank       int
+    SuperAdmin bool
+}
+
+type PrivilegeService struct {
+    DB *gorm.DB
+}
+
+func NewPrivilegeService(db *gorm.DB) *PrivilegeService {
+    return &PrivilegeService{DB: db}
+}
+
+// GetPrivileges returns the highest rank among the user's active roles and
+// whether one of them is a super-admin role. Super-admins outrank everyone.
+func (t *PrivilegeService) GetPrivileges(userId string) (*Privileges, int, error) {
+    var roles []models.Role
+    err := t.DB.Model(&models.Role{}).
+        Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+        Scopes(ActiveRoleAssignments).
+        Where("user_roles.user_id = ?", userId).
+        Find(&roles).Error
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+
+    privileges := &Privileges{}
+    superAdminRoles := SuperAdminRoles()
+    for i, role := range roles {
+        if i == 0 || role.Rank > privileges.Rank {
+            privileges.Rank = role.Rank
+        }
+        for _, name := range superAdminRoles {
+            if role.Name == name {
+                privileges.SuperAdmin = true
+            }
+        }
+    }
+    return privileges, http.StatusOK, nil
+}
+
+// errUnranked refuses callers without a ranked role, who would otherwise be
+// equal to every role nobody has ranked yet.
+var errUnranked = &PrivilegeError{
+    Reason:  ReasonUnranked,
+    Message: "None of your roles is ranked, so you cannot manage roles",
+}
+
+// CheckRoleRanks refuses when any of the roles is ranked above the caller, or
+// the caller is unranked.
+func (t *PrivilegeService) CheckRoleRanks(callerId string, roleIds ...int) (int, error) {
+    privileges, code, err := t.GetPrivileges(callerId)
+    if err != nil {
+        return code, err
+    }
+    if privileges.SuperAdmin || len(roleIds) == 0 {
+        return http.StatusOK, nil
+    }
+    if privileges.Rank <= 0 {
+        return http.StatusForbidden, errUnranked
+    }
+
+    var roles []models.Role
+    if err := t.DB.Where("id IN ?", roleIds).Order("role_rank DESC").Find(&roles).Error; err != nil {
+        return http.StatusInternalServerError, err
+    }
+    for _, role := range roles {
+        if role.Rank > privileges.Rank {
+            return http.StatusForbidden, &PrivilegeError{
+                Reason:  ReasonRankExceeded,
+                Message: fmt.Sprintf("Role %s is ranked above your own", role.Name),
+            }
+        }
+    }
+    return http.StatusOK, nil
+}
+
+// CheckRank refuses when rank is above the caller's, or the caller is unranked.
+func (t *PrivilegeService) CheckRank(callerId string, rank int) (int, error) {
+    privileges, code, err := t.GetPrivileges(callerId)
+    if err != nil {
+        return code, err
+    }
+    if privileges.SuperAdmin {
+        return http.StatusOK, nil
+    }
+    if privileges.Rank <= 0 {
+        return http.StatusForbidden, errUnranked
+    }
+    if rank > privileges.Rank {
+        return http.StatusForbidden, &PrivilegeError{
+            Reason:  ReasonRankExceeded,
+            Message: fmt.Sprintf("Rank %d is above your own", rank),
+        }
+    }
+    return http.StatusOK, nil
+}
+
+// CheckUserChange refuses to let the caller modify a user who holds a role
+// ranked above the caller's, and to change their own roles when roleIds are
+// given. roleIds are the roles being assigned or revoked.
+func (t *PrivilegeService) CheckUserChange(callerId, targetId string, roleIds ...int) (int, error) {
+    if len(roleIds) > 0 && callerId == targetId {
+        return http.StatusForbidden, &PrivilegeError{
+            Reason:  ReasonSelfRoleChange,
+            Message: "Cannot change your own roles",
+        }
+    }
+
+    var heldRoleIds []int
+    err := t.DB.Model(&models.UserRole{}).Scopes(ActiveRoleAssignments).
+        Where("user_id = ?", targetId).
+        Pluck("role_id", &heldRoleIds).Error
+    if err != nil {
+        return http.StatusInternalServerError, err
+    }
+
+    return t.CheckRoleRanks(callerId, append(heldRoleIds, roleIds...)...)
+}
+
+// CheckAccessPoints refuses unless the caller is a super-admin when any of the
+// access points is an admin access point.
+func (t *PrivilegeService) CheckAccessPoints(callerId string, apIds ...int) (int, error) {
+    var count int64
+    err := t.DB.Model(&models.AccessPoint{}).Where("id IN ? AND is_admin = ?", apIds, true).Count(&count).Error
+    if err != nil {
+        return http.StatusInternalServerError, err
+    }
+    if count == 0 {
+        return http.StatusOK, nil
+    }
+    return t.CheckSuperAdmin(callerId)
+}
+
+// CheckSuperAdmin refuses unless the caller holds a super-admin role.
+func (t *PrivilegeService) CheckSuperAdmin(callerId string) (int, error) {
+    privileges, code, err := t.GetPrivileges(callerId)
+    if err != nil {
+        return code, err
+    }
+    if !privileges.SuperAdmin {
+        return http.StatusForbidden, &PrivilegeError{
+            Reason:  ReasonSuperAdminRequired,
+            Message: "Only a super-admin can manage admin access points",
+        }
+    }
+    return http.StatusOK, nil
+}
+
+// PrivilegeReason returns the reason code of a PrivilegeError or
+// FieldPolicyError, if err is one.
+func PrivilegeReason(err error) string {
+    var privilegeErr *PrivilegeError
+    if errors.As(err, &privilegeErr) {
+        return privilegeErr.Reason
+    }
+    var fieldPolicyErr *FieldPolicyError
+    if errors.As(err, &fieldPolicyErr) {
+        return ReasonFieldNotWritable
+    }
+    return ""
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckUserChange_SelfRoleChange(t *testing.T) {
+    privilegeService := NewPrivilegeService(gormDB)
+
+    statusCode, err := privilegeService.CheckUserChange("1", "1", 2)
+
+    assert.Equal(t, http.StatusForbidden, statusCode)
+    assert.Equal(t, ReasonSelfRoleChange, PrivilegeReason(err))
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckUserChange_RankExceeded(t *testing.T) {
+    privilegeService := NewPrivilegeService(gormDB)
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `role_id` FROM `user_roles` WHERE user_id = ?")).
+        WithArgs("2", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(3))
+    mock.ExpectQuery(regexp.QuoteMeta("FROM `roles` JOIN user_roles ON user_roles.role_id = roles.id")).
+        WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "role_rank"}).AddRow(4, "Admin", nil, 10))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `roles` WHERE id IN (?) ORDER BY role_rank DESC")).
+        WithArgs(3).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "role_rank"}).AddRow(3, "Owner", nil, 20))
+
+    statusCode, err := privilegeService.CheckUserChange("1", "2")
+
+    assert.Equal(t, http.StatusForbidden, statusCode)
+    assert.Equal(t, ReasonRankExceeded, PrivilegeReason(err))
+    assert.EqualError(t, err, "Role Owner is ranked above your own")
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckRank_Unranked(t *testing.T) {
+    privilegeService := NewPrivilegeService(gormDB)
+
+    // Rank 0 would otherwise equal every role nobody has ranked yet
+    mock.ExpectQuery(regexp.QuoteMeta("FROM `roles` JOIN user_roles ON user_roles.role_id = roles.id")).
+        WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "role_rank"}).AddRow(4, "Support", nil, 0))
+
+    statusCode, err := privilegeService.CheckRank("1", 0)
+
+    assert.Equal(t, http.StatusForbidden, statusCode)
+    assert.Equal(t, ReasonUnranked, PrivilegeReason(err))
+
+    mock.ExpectQuery(regexp.QuoteMeta("FROM `roles` JOIN user_roles ON user_roles.role_id = roles.id")).
+        WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "role_rank"}).AddRow(4, "Support", nil, 0))
+
+    statusCode, err = privilegeService.CheckRoleRanks("1", 4)
+
+    assert.Equal(t, http.StatusForbidden, statusCode)
+    assert.Equal(t, ReasonUnranked, PrivilegeReason(err))
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckAccessPoints_SuperAdminRequired(t *testing.T) {
+    privilegeService := NewPrivilegeService(gormDB)
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `access_points` WHERE id IN (?) AND is_admin = ?")).
+        WithArgs(9, true).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+    mock.ExpectQuery(regexp.QuoteMeta("JOIN user_roles ON user_roles.role_id = roles.id")).
+        WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "role_rank"}).AddRow(4, "Admin", nil, 10))
+
+    statusCode, err := privilegeService.CheckAccessPoints("1", 9)
+
+    assert.Equal(t, http.StatusForbidden, statusCode)
+    assert.Equal(t, ReasonSuperAdminRequired, PrivilegeReason(err))
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
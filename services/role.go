@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"user-storage/models"
+
+	"gorm.io/gorm"
+)
+
+// Guards against corrupt data: no sane hierarchy is deeper than this.
+const maxRoleDepth = 32
+
+var ErrRoleCycle = errors.New("Parent role would create a cycle in the role hierarchy")
+
+type RoleService struct {
+    DB *gorm.DB
+}
+
+func NewRoleService(db *gorm.DB) *RoleService {
+    return &RoleService{DB: db}
+}
+
+// GetRoleChain returns the role followed by its ancestors, nearest first.
+func (t *RoleService) GetRoleChain(roleId int) ([]models.Role, int, error) {
+    var chain []models.Role
+    visited := map[int]bool{}
+
+    nextId := &roleId
+    for nextId != nil {
+        if visited[*nextId] || len(chain) >= maxRoleDepth {
+            return nil, http.StatusInternalServerError, ErrRoleCycle
+        }
+        visited[*nextId] = true
+
+        var role models.Role
+        err := t.DB.First(&role, "id = ?", *nextId).Error
+        if err != nil {
+            if errors.Is(err, gorm.ErrRecordNotFound) {
+                if len(chain) == 0 {
+                    return nil, http.StatusNotFound, errors.New("Role ID is not found")
+                }
+                // Dangling parent reference, treat the chain as ending here
+                break
+            }
+            return nil, http.StatusInternalServerError, err
+        }
+
+        chain = append(chain, role)
+        nextId = role.ParentId
+    }
+
+    return chain, http.StatusOK, nil
+}
+
+// ValidateParent checks that parentId exists and that making it the parent of
+// roleId would not create a cycle. roleId is 0 for roles not yet created.
+func (t *RoleService) ValidateParent(roleId int, parentId *int) (int, error) {
+    if parentId == nil {
+        return http.StatusOK, nil
+    }
+    if roleId != 0 && *parentId == roleId {
+        return http.StatusBadRequest, ErrRoleCycle
+    }
+
+    ancestors, code, err := t.GetRoleChain(*parentId)
+    if err != nil {
+        if code == http.StatusNotFound {
+            return http.StatusBadRequest, fmt.Errorf("Parent role %d is not found", *parentId)
+        }
+        return code, err
+    }
+    for _, ancestor := range ancestors {
+        if ancestor.Id == roleId {
+            return http.StatusBadRequest, ErrRoleCycle
+        }
+    }
+
+    return http.StatusOK, nil
+}
+
+// grantKey identifies a grant independently of the role holding it. Grants of
+// one access point under different conditions are kept apart, since any of
+// them may allow a request.
+type grantKey struct {
+    apId       int
+    conditions string
+}
+
+// GetRoleGrants returns every access point the role can use, from its own
+// role_access rows and those of its ancestors. When several roles in the chain
+// grant the same access point under the same conditions, the nearest grant
+// is returned and the others are listed in its AlsoGrantedBy, nearest first.
+func (t *RoleService) GetRoleGrants(roleId int) (*[]models.Permission, int, error) {
+    chain, code, err := t.GetRoleChain(roleId)
+    if err != nil {
+        return nil, code, err
+    }
+
+    distance := map[int]int{}
+    roleIds := make([]int, 0, len(chain))
+    for i, role := range chain {
+        distance[role.Id] = i
+        roleIds = append(roleIds, role.Id)
+    }
+
+    var grants []models.Permission
+    err = t.DB.Table("access_points").
+        Select("access_points.*, roles.id AS role_id, roles.name AS role_name, role_access.conditions").
+        Joins("JOIN role_access ON role_access.ap_id = access_points.id").
+        Joins("JOIN roles ON roles.id = role_access.role_id").
+        Where("role_access.role_id IN ?", roleIds).
+        Order("access_points.id").
+        Scan(&grants).Error
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+
+    nearest := map[grantKey]int{}
+    permissions := []models.Permission{}
+    for _, grant := range grants {
+        grant.Grant.Inherited = grant.Grant.RoleId != roleId
+        key := grantKey{grant.AccessPoint.Id, grant.Grant.Conditions}
+        if i, seen := nearest[key]; seen {
+            if distance[grant.Grant.RoleId] < distance[permissions[i].Grant.RoleId] {
+                mergeGrant(&grant.Grant, permissions[i].Grant)
+                permissions[i] = grant
+            } else {
+                mergeGrant(&permissions[i].Grant, grant.Grant)
+            }
+            continue
+        }
+        nearest[key] = len(permissions)
+        permissions = append(permissions, grant)
+    }
+    for _, permission := range permissions {
+        sources := permission.Grant.AlsoGrantedBy
+        sort.SliceStable(sources, func(i, j int) bool {
+            return distance[sources[i].RoleId] < distance[sources[j].RoleId]
+        })
+    }
+
+    return &permissions, http.StatusOK, nil
+}
+
+// mergeGrant records other, and the roles behind it, in kept's AlsoGrantedBy
+// when both grant the same access point.
+func mergeGrant(kept *models.Grant, other models.Grant) {
+    sources := append([]models.GrantSource{{RoleId: other.RoleId, RoleName: other.RoleName, Inherited: other.Inherited}}, other.AlsoGrantedBy...)
+    for _, source := range sources {
+        if source.RoleId == kept.RoleId {
+            continue
+        }
+        known := false
+        for _, existing := range kept.AlsoGrantedBy {
+            known = known || existing.RoleId == source.RoleId
+        }
+        if !known {
+            kept.AlsoGrantedBy = append(kept.AlsoGrantedBy, source)
+        }
+    }
+}
+
+// HasChildren reports whether any role names roleId as its parent.
+func (t *RoleService) HasChildren(roleId int) (bool, int, error) {
+    var count int64
+    if err := t.DB.Model(&models.Role{}).Where("parent_id = ?", roleId).Count(&count).Error; err != nil {
+        return false, http.StatusInternalServerError, err
+    }
+    return count > 0, http.StatusOK, nil
+}
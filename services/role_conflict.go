@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"user-storage/models"
+
+	"gorm.io/gorm"
+)
+
+// RoleConflictError is returned when an assignment would give a user two
+// roles that a RoleConflict keeps apart.
+type RoleConflictError struct {
+    Conflict string
+    Roles    []string
+}
+
+func (e *RoleConflictError) Error() string {
+    return fmt.Sprintf("Roles %s cannot be held together (%s)", strings.Join(e.Roles, ", "), e.Conflict)
+}
+
+type RoleConflictService struct {
+    DB *gorm.DB
+}
+
+func NewRoleConflictService(db *gorm.DB) *RoleConflictService {
+    return &RoleConflictService{DB: db}
+}
+
+func (t *RoleConflictService) GetAllRoleConflicts() (*[]models.RoleConflict, int, error) {
+    var conflicts []models.RoleConflict
+    if err := t.DB.Order("id").Find(&conflicts).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+
+    var members []models.RoleConflictMember
+    if err := t.DB.Order("role_id").Find(&members).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    roleIds := map[int][]int{}
+    for _, member := range members {
+        roleIds[member.ConflictId] = append(roleIds[member.ConflictId], member.RoleId)
+    }
+    for i := range conflicts {
+        conflicts[i].RoleIds = roleIds[conflicts[i].Id]
+    }
+
+    return &conflicts, http.StatusOK, nil
+}
+
+func (t *RoleConflictService) AddRoleConflict(conflict *models.RoleConflict) (*models.RoleConflict, int, error) {
+    if err := validate.Struct(conflict); err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+
+    var count int64
+    if err := t.DB.Model(&models.Role{}).Where("id IN ?", conflict.RoleIds).Count(&count).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    if int(count) != len(conflict.RoleIds) {
+        return nil, http.StatusBadRequest, errors.New("Every role in the conflict must exist")
+    }
+
+    tx := t.DB.Begin()
+    if err := tx.Create(conflict).Error; err != nil {
+        tx.Rollback()
+        return nil, http.StatusInternalServerError, err
+    }
+    for _, roleId := range conflict.RoleIds {
+        if err := tx.Create(&models.RoleConflictMember{ConflictId: conflict.Id, RoleId: roleId}).Error; err != nil {
+            tx.Rollback()
+            return nil, http.StatusInternalServerError, err
+        }
+    }
+    tx.Commit()
+
+    return conflict, http.StatusCreated, nil
+}
+
+func (t *RoleConflictService) DeleteRoleConflict(id string) (*models.RoleConflict, int, error) {
+    var conflict models.RoleConflict
+    if err := t.DB.First(&conflict, "id = ?", id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, http.StatusNotFound, errors.New("Role conflict is not found")
+        }
+        return nil, http.StatusInternalServerError, err
+    }
+
+    tx := t.DB.Begin()
+    if err := tx.Where("conflict_id = ?", conflict.Id).Delete(&models.RoleConflictMember{}).Error; err != nil {
+        tx.Rollback()
+        return nil, http.StatusInternalServerError, err
+    }
+    if err := tx.Delete(&conflict).Error; err != nil {
+        tx.Rollback()
+        return nil, http.StatusInternalServerError, err
+    }
+    tx.Commit()
+
+    return &conflict, http.StatusOK, nil
+}
+
+// GetViolations lists every user currently holding several roles of the same
+// conflict, such as assignments made before the conflict was defined.
+func (t *RoleConflictService) GetViolations() (*[]models.RoleConflictViolation, int, error) {
+    conflicts, code, err := t.GetAllRoleConflicts()
+    if err != nil {
+        return nil, code, err
+    }
+
+    violations := []models.RoleConflictViolation{}
+    for _, conflict := range *conflicts {
+        var assignments []models.UserRole
+        err := t.DB.Scopes(unexpiredRoleAssignments).
+            Where("role_id IN ?", conflict.RoleIds).
+            Order("user_id, role_id").
+            Find(&assignments).Error
+        if err != nil {
+            return nil, http.StatusInternalServerError, err
+        }
+
+        heldBy := map[string][]int{}
+        var userIds []string
+        for _, assignment := range assignments {
+            if _, ok := heldBy[assignment.UserId]; !ok {
+                userIds = append(userIds, assignment.UserId)
+            }
+            heldBy[assignment.UserId] = append(heldBy[assignment.UserId], int(assignment.RoleId))
+        }
+
+        for _, userId := range userIds {
+            if len(heldBy[userId]) < 2 {
+                continue
+            }
+            var roles []models.Role
+            if err := t.DB.Where("id IN ?", heldBy[userId]).Order("id").Find(&roles).Error; err != nil {
+                return nil, http.StatusInternalServerError, err
+            }
+            violations = append(violations, models.RoleConflictViolation{
+                Conflict: conflict,
+                UserId:   userId,
+                Roles:    roles,
+            })
+        }
+    }
+
+    return &violations, http.StatusOK, nil
+}
+
+// unexpiredRoleAssignments keeps current and future-dated assignments, since
+// scheduling a conflicting role is as much a violation as holding it now.
+func unexpiredRoleAssignments(db *gorm.DB) *gorm.DB {
+    return db.Where("user_roles.valid_until IS NULL OR user_roles.valid_until > ?", time.Now())
+}
+
+// checkRoleConflicts returns a RoleConflictError if any conflict contains two
+// or more of the given roles.
+func checkRoleConflicts(db *gorm.DB, roleIds []uint) (int, error) {
+    unique := map[uint]bool{}
+    for _, roleId := range roleIds {
+        unique[roleId] = true
+    }
+    if len(unique) < 2 {
+        return http.StatusOK, nil
+    }
+    ids := make([]uint, 0, len(unique))
+    for roleId := range unique {
+        ids = append(ids, roleId)
+    }
+    sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+    var members []models.RoleConflictMember
+    if err := db.Where("role_id IN ?", ids).Order("conflict_id, role_id").Find(&members).Error; err != nil {
+        return http.StatusInternalServerError, err
+    }
+
+    held := map[int][]int{}
+    var conflictIds []int
+    for _, member := range members {
+        if _, ok := held[member.ConflictId]; !ok {
+            conflictIds = append(conflictIds, member.ConflictId)
+        }
+        held[member.ConflictId] = append(held[member.ConflictId], member.RoleId)
+    }
+
+    for _, conflictId := range conflictIds {
+        if len(held[conflictId]) < 2 {
+            continue
+        }
+        var conflict models.RoleConflict
+        if err := db.First(&conflict, "id = ?", conflictId).Error; err != nil {
+            return http.StatusInternalServerError, err
+        }
+        var names []string
+        if err := db.Model(&models.Role{}).Where("id IN ?", held[conflictId]).Order("id").Pluck("name", &names).Error; err != nil {
+            return http.StatusInternalServerError, err
+        }
+        return http.StatusConflict, &RoleConflictError{Conflict: conflict.Name, Roles: names}
+    }
+
+    return http.StatusOK, nil
+}
+
+// heldRoleIds returns the roles the user holds or is scheduled to hold.
+func heldRoleIds(db *gorm.DB, userId string) ([]uint, error) {
+    var roleIds []uint
+    err := db.Model(&models.UserRole{}).Scopes(unexpiredRoleAssignments).
+        Where("user_id = ?", userId).
+        Pluck("role_id", &roleIds).Error
+    return roleIds, err
+}
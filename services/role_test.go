@@ -0,0 +1,76 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var roleColumns = []string{"id", "name", "parent_id"}
+
+func TestGetRoleGrants_Inherited(t *testing.T) {
+    roleService := NewRoleService(gormDB)
+
+    // Manager (3) -> Owner (2) -> Admin (1)
+    statement := "SELECT * FROM `roles` WHERE id = ?"
+    mock.ExpectQuery(regexp.QuoteMeta(statement)).WithArgs(3).
+        WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "Manager", 2))
+    mock.ExpectQuery(regexp.QuoteMeta(statement)).WithArgs(2).
+        WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(2, "Owner", 1))
+    mock.ExpectQuery(regexp.QuoteMeta(statement)).WithArgs(1).
+        WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(1, "Admin", nil))
+
+    mock.ExpectQuery(regexp.QuoteMeta("WHERE role_access.role_id IN (?,?,?)")).
+        WithArgs(3, 2, 1).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "method", "endpoint", "role_id", "role_name"}).
+            AddRow(1, "List users", "GET", "/users/accounts", 1, "Admin").
+            AddRow(1, "List users", "GET", "/users/accounts", 3, "Manager").
+            AddRow(2, "Delete user", "DELETE", "/users/accounts/:id", 2, "Owner"))
+
+    grants, statusCode, err := roleService.GetRoleGrants(3)
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Len(t, *grants, 2)
+    assert.Equal(t, 3, (*grants)[0].Grant.RoleId)
+    assert.False(t, (*grants)[0].Grant.Inherited)
+    // Revoking Manager's own grant would still leave the one inherited from Admin
+    assert.Equal(t, []models.GrantSource{{RoleId: 1, RoleName: "Admin", Inherited: true}}, (*grants)[0].Grant.AlsoGrantedBy)
+    assert.Equal(t, 2, (*grants)[1].Grant.RoleId)
+    assert.True(t, (*grants)[1].Grant.Inherited)
+    assert.Empty(t, (*grants)[1].Grant.AlsoGrantedBy)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestValidateParent_Cycle(t *testing.T) {
+    roleService := NewRoleService(gormDB)
+    parentId := 3
+
+    // Making Manager (3) the parent of Admin (1) closes the loop
+    statement := "SELECT * FROM `roles` WHERE id = ?"
+    mock.ExpectQuery(regexp.QuoteMeta(statement)).WithArgs(3).
+        WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "Manager", 2))
+    mock.ExpectQuery(regexp.QuoteMeta(statement)).WithArgs(2).
+        WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(2, "Owner", 1))
+    mock.ExpectQuery(regexp.QuoteMeta(statement)).WithArgs(1).
+        WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(1, "Admin", nil))
+
+    statusCode, err := roleService.ValidateParent(1, &parentId)
+
+    assert.ErrorIs(t, err, ErrRoleCycle)
+    assert.Equal(t, http.StatusBadRequest, statusCode)
+}
+
+func TestValidateParent_Self(t *testing.T) {
+    roleService := NewRoleService(gormDB)
+    parentId := 4
+
+    statusCode, err := roleService.ValidateParent(4, &parentId)
+
+    assert.ErrorIs(t, err, ErrRoleCycle)
+    assert.Equal(t, http.StatusBadRequest, statusCode)
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Filter expressions follow the SCIM filter grammar (RFC 7644 3.4.2.2):
+//
+//	role eq 2 and (email ew "@bank.com" or lastName sw "Ta")
+//
+// Attributes are limited to filterFields. Operators and attribute names are
+// case-insensitive. role compares against the roles a user actively holds.
+// Filters are limited to MaxFilterTerms comparisons nested at most
+// MaxFilterDepth parentheses or nots deep, keeping both parsing and the
+// resulting query bounded.
+
+type filterKind int
+
+const (
+    filterString filterKind = iota
+    filterTime
+    filterRole
+)
+
+type filterField struct {
+    Name   string
+    Column string
+    Kind   filterKind
+}
+
+var filterFields = map[string]filterField{
+    "id":        {"id", "id", filterString},
+    "firstname": {"firstName", "first_name", filterString},
+    "lastname":  {"lastName", "last_name", filterString},
+    "email":     {"email", "email", filterString},
+    "createdby": {"createdBy", "created_by", filterString},
+    "updatedby": {"updatedBy", "updated_by", filterString},
+    "createdat": {"createdAt", "created_at", filterTime},
+    "updatedat": {"updatedAt", "updated_at", filterTime},
+    "role":      {"role", "", filterRole},
+}
+
+var filterOperators = map[filterKind][]string{
+    filterString: {"eq", "ne", "co", "sw", "ew", "gt", "lt", "ge", "le", "pr"},
+    filterTime:   {"eq", "ne", "gt", "lt", "ge", "le", "pr"},
+    filterRole:   {"eq", "ne", "pr"},
+}
+
+var filterComparisons = map[string]string{
+    "eq": "=", "ne": "<>", "gt": ">", "lt": "<", "ge": ">=", "le": "<=",
+}
+
+const (
+    ReasonInvalidFilter = "invalid_filter"
+
+    MaxFilterDepth = 8
+    MaxFilterTerms = 32
+)
+
+// FilterError points at the token of a filter expression that could not be
+// parsed. Position counts characters from 1.
+type FilterError struct {
+    Position int
+    Token    string
+    Message  string
+}
+
+func (e *FilterError) Error() string {
+    if e.Token == "" {
+        return fmt.Sprintf("%s at end of filter", e.Message)
+    }
+    return fmt.Sprintf("%s at position %d near %q", e.Message, e.Position, e.Token)
+}
+
+type tokenKind int
+
+const (
+    tokenEnd tokenKind = iota
+    tokenWord
+    tokenString
+    tokenNumber
+    tokenOpen
+    tokenClose
+)
+
+type filterToken struct {
+    Kind     tokenKind
+    Text     string
+    Value    string
+    Position int
+}
+
+func lexFilter(input string) ([]filterToken, error) {
+    var tokens []filterToken
+    runes := []rune(input)
+    for i := 0; i < len(runes); {
+        r := runes[i]
+        start := i
+        switch {
+        case unicode.IsSpace(r):
+            i++
+            continue
+        case r == '(':
+            tokens = append(tokens, filterToken{Kind: tokenOpen, Text: "(", Position: start + 1})
+            i++
+        case r == ')':
+            tokens = append(tokens, filterToken{Kind: tokenClose, Text: ")", Position: start + 1})
+            i++
+        case r == '"':
+            i++
+            for i < len(runes) && runes[i] != '"' {
+                if runes[i] == '\\' {
+                    i++
+                }
+                i++
+            }
+            if i >= len(runes) {
+                return nil, &FilterError{Position: start + 1, Token: string(runes[start:]), Message: "Unterminated string"}
+            }
+            i++
+            text := string(runes[start:i])
+            var value string
+            if err := json.Unmarshal([]byte(text), &value); err != nil {
+                return nil, &FilterError{Position: start + 1, Token: text, Message: "Invalid string"}
+            }
+            tokens = append(tokens, filterToken{Kind: tokenString, Text: text, Value: value, Position: start + 1})
+        case r == '-' || unicode.IsDigit(r):
+            i++
+            for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+                i++
+            }
+            text := string(runes[start:i])
+            if _, err := strconv.ParseFloat(text, 64); err != nil {
+                return nil, &FilterError{Position: start + 1, Token: text, Message: "Invalid number"}
+            }
+            tokens = append(tokens, filterToken{Kind: tokenNumber, Text: text, Value: text, Position: start + 1})
+        case unicode.IsLetter(r):
+            for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == '_') {
+                i++
+            }
+            text := string(runes[start:i])
+            tokens = append(tokens, filterToken{Kind: tokenWord, Text: text, Value: text, Position: start + 1})
+        default:
+            return nil, &FilterError{Position: start + 1, Token: string(r), Message: "Unexpected character"}
+        }
+    }
+    return append(tokens, filterToken{Kind: tokenEnd, Position: len(runes) + 1}), nil
+}
+
+// FilterNode is a parsed filter expression.
+type FilterNode interface {
+    expression(db *gorm.DB) clause.Expr
+    // attributes appends the names of the fields the expression compares.
+    attributes(names []string) []string
+}
+
+type filterLogical struct {
+    Operator string
+    Left     FilterNode
+    Right    FilterNode
+}
+
+type filterNot struct {
+    Operand FilterNode
+}
+
+type filterComparison struct {
+    Field    filterField
+    Operator string
+    Value    interface{}
+}
+
+type filterParser struct {
+    tokens []filterToken
+    pos    int
+    depth  int
+    terms  int
+}
+
+func (p *filterParser) peek() filterToken {
+    return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+    token := p.tokens[p.pos]
+    if token.Kind != tokenEnd {
+        p.pos++
+    }
+    return token
+}
+
+func (p *filterParser) keyword(word string) bool {
+    token := p.peek()
+    return token.Kind == tokenWord && strings.EqualFold(token.Text, word)
+}
+
+func unexpected(token filterToken, message string) error {
+    return &FilterError{Position: token.Position, Token: token.Text, Message: message}
+}
+
+// ParseFilter parses a SCIM filter expression over the user fields.
+func ParseFilter(input string) (FilterNode, error) {
+    tokens, err := lexFilter(input)
+    if err != nil {
+        return nil, err
+    }
+    p := &filterParser{tokens: tokens}
+    node, err := p.parseOr()
+    if err != nil {
+        return nil, err
+    }
+    if token := p.peek(); token.Kind != tokenEnd {
+        return nil, unexpected(token, "Expected and, or or the end of the filter")
+    }
+    return node, nil
+}
+
+func (p *filterParser) parseOr() (FilterNode, error) {
+    left, err := p.parseAnd()
+    if err != nil {
+        return nil, err
+    }
+    for p.keyword("or") {
+        p.next()
+        right, err := p.parseAnd()
+        if err != nil {
+            return nil, err
+        }
+        left = filterLogical{Operator: "OR", Left: left, Right: right}
+    }
+    return left, nil
+}
+
+func (p *filterParser) parseAnd() (FilterNode, error) {
+    left, err := p.parseUnary()
+    if err != nil {
+        return nil, err
+    }
+    for p.keyword("and") {
+        p.next()
+        right, err := p.parseUnary()
+        if err != nil {
+            return nil, err
+        }
+        left = filterLogical{Operator: "AND", Left: left, Right: right}
+    }
+    return left, nil
+}
+
+// nest enters a not or parenthesis, refusing those past MaxFilterDepth. The
+// caller leaves it by decrementing depth.
+func (p *filterParser) nest() error {
+    if p.depth++; p.depth > MaxFilterDepth {
+        return unexpected(p.peek(), fmt.Sprintf("Filter nests deeper than %d", MaxFilterDepth))
+    }
+    return nil
+}
+
+func (p *filterParser) parseUnary() (FilterNode, error) {
+    if p.keyword("not") {
+        if err := p.nest(); err != nil {
+            return nil, err
+        }
+        p.next()
+        operand, err := p.parseUnary()
+        if err != nil {
+            return nil, err
+        }
+        p.depth--
+        return filterNot{Operand: operand}, nil
+    }
+    if p.peek().Kind == tokenOpen {
+        if err := p.nest(); err != nil {
+            return nil, err
+        }
+        p.next()
+        node, err := p.parseOr()
+        if err != nil {
+            return nil, err
+        }
+        p.depth--
+        if token := p.next(); token.Kind != tokenClose {
+            return nil, unexpected(token, "Expected )")
+        }
+        return node, nil
+    }
+    return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (FilterNode, error) {
+    if p.terms++; p.terms > MaxFilterTerms {
+        return nil, unexpected(p.peek(), fmt.Sprintf("Filter has more than %d comparisons", MaxFilterTerms))
+    }
+    attribute := p.next()
+    if attribute.Kind != tokenWord {
+        return nil, unexpected(attribute, "Expected an attribute")
+    }
+    field, ok := filterFields[strings.ToLower(attribute.Text)]
+    if !ok {
+        return nil, unexpected(attribute, "Unknown attribute")
+    }
+
+    operatorToken := p.next()
+    if operatorToken.Kind != tokenWord {
+        return nil, unexpected(operatorToken, "Expected an operator")
+    }
+    operator := strings.ToLower(operatorToken.Text)
+    supported := false
+    for _, allowed := range filterOperators[field.Kind] {
+        supported = supported || allowed == operator
+    }
+    if !supported {
+        return nil, unexpected(operatorToken, fmt.Sprintf("Operator is not supported for %s", field.Name))
+    }
+    if operator == "pr" {
+        return filterComparison{Field: field, Operator: operator}, nil
+    }
+
+    valueToken := p.next()
+    var value interface{}
+    switch field.Kind {
+    case filterString:
+        if valueToken.Kind != tokenString {
+            return nil, unexpected(valueToken, fmt.Sprintf("Expected a string to compare %s with", field.Name))
+        }
+        value = valueToken.Value
+    case filterTime:
+        if valueToken.Kind != tokenString {
+            return nil, unexpected(valueToken, fmt.Sprintf("Expected an RFC 3339 time to compare %s with", field.Name))
+        }
+        parsed, err := time.Parse(time.RFC3339, valueToken.Value)
+        if err != nil {
+            return nil, unexpected(valueToken, "Invalid RFC 3339 time")
+        }
+        value = parsed
+    case filterRole:
+        role, err := strconv.Atoi(valueToken.Value)
+        if valueToken.Kind != tokenNumber || err != nil {
+            return nil, unexpected(valueToken, "Expected a role id")
+        }
+        value = role
+    }
+    return filterComparison{Field: field, Operator: operator, Value: value}, nil
+}
+
+func (n filterLogical) expression(db *gorm.DB) clause.Expr {
+    left, right := n.Left.expression(db), n.Right.expression(db)
+    return clause.Expr{
+        SQL:  "(" + left.SQL + " " + n.Operator + " " + right.SQL + ")",
+        Vars: append(append([]interface{}{}, left.Vars...), right.Vars...),
+    }
+}
+
+func (n filterNot) expression(db *gorm.DB) clause.Expr {
+    operand := n.Operand.expression(db)
+    return clause.Expr{SQL: "NOT (" + operand.SQL + ")", Vars: operand.Vars}
+}
+
+func (n filterLogical) attributes(names []string) []string {
+    return n.Right.attributes(n.Left.attributes(names))
+}
+
+func (n filterNot) attributes(names []string) []string {
+    return n.Operand.attributes(names)
+}
+
+func (n filterComparison) attributes(names []string) []string {
+    return append(names, n.Field.Name)
+}
+
+// escapeLike makes value match literally inside a LIKE pattern.
+func escapeLike(value string) string {
+    return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+func (n filterComparison) expression(db *gorm.DB) clause.Expr {
+    if n.Field.Kind == filterRole {
+        switch n.Operator {
+        case "pr":
+            return clause.Expr{SQL: "id IN (?)", Vars: []interface{}{usersWithActiveRoles(db)}}
+        case "ne":
+            return clause.Expr{SQL: "id NOT IN (?)", Vars: []interface{}{usersWithActiveRoles(db, n.Value.(int))}}
+        }
+        return clause.Expr{SQL: "id IN (?)", Vars: []interface{}{usersWithActiveRoles(db, n.Value.(int))}}
+    }
+
+    column := n.Field.Column
+    switch n.Operator {
+    case "pr":
+        if n.Field.Kind == filterString {
+            return clause.Expr{SQL: "(" + column + " IS NOT NULL AND " + column + " <> '')"}
+        }
+        return clause.Expr{SQL: column + " IS NOT NULL"}
+    case "co":
+        return clause.Expr{SQL: column + " LIKE ?", Vars: []interface{}{"%" + escapeLike(n.Value.(string)) + "%"}}
+    case "sw":
+        return clause.Expr{SQL: column + " LIKE ?", Vars: []interface{}{escapeLike(n.Value.(string)) + "%"}}
+    case "ew":
+        return clause.Expr{SQL: column + " LIKE ?", Vars: []interface{}{"%" + escapeLike(n.Value.(string))}}
+    }
+    return clause.Expr{SQL: column + " " + filterComparisons[n.Operator] + " ?", Vars: []interface{}{n.Value}}
+}
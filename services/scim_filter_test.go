@@ -0,0 +1,44 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"user-storage/models"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestParseFilter(t *testing.T) {
+    node, err := ParseFilter(`role eq 2 and (email ew "@bank.com" or not lastName sw "T_a")`)
+    assert.NoError(t, err)
+
+    var users []models.User
+    stmt := gormDB.Session(&gorm.Session{DryRun: true}).Where(node.expression(gormDB)).Find(&users).Statement
+
+    assert.Equal(t, "SELECT * FROM `users` WHERE ((id IN (SELECT user_roles.user_id FROM `user_roles` WHERE user_roles.role_id IN (?) AND ((user_roles.valid_from IS NULL OR user_roles.valid_from <= ?) AND (user_roles.valid_until IS NULL OR user_roles.valid_until > ?))) AND (email LIKE ? OR NOT (last_name LIKE ?)))) AND `users`.`deleted_at` IS NULL", stmt.SQL.String())
+    assert.Equal(t, "%@bank.com", stmt.Vars[3])
+    assert.Equal(t, `T\_a%`, stmt.Vars[4])
+
+    cases := map[string]string{
+        `email eq "a" and`:       "Expected an attribute at end of filter",
+        `password eq "x"`:        `Unknown attribute at position 1 near "password"`,
+        `role co 2`:              `Operator is not supported for role at position 6 near "co"`,
+        `(email pr or id pr`:     "Expected ) at end of filter",
+        `createdAt gt "monday"`:  `Invalid RFC 3339 time at position 14 near "\"monday\""`,
+        `email eq "a" id pr`:     `Expected and, or or the end of the filter at position 14 near "id"`,
+        `email eq "unterminated`: `Unterminated string at position 10 near "\"unterminated"`,
+    }
+    for input, message := range cases {
+        _, err := ParseFilter(input)
+        assert.EqualError(t, err, message, input)
+    }
+
+    // Nesting and comparisons are capped
+    _, err = ParseFilter(strings.Repeat("(", MaxFilterDepth) + `id pr` + strings.Repeat(")", MaxFilterDepth))
+    assert.NoError(t, err)
+    _, err = ParseFilter(strings.Repeat("not ", MaxFilterDepth+1) + `id pr`)
+    assert.EqualError(t, err, `Filter nests deeper than 8 at position 33 near "not"`)
+    _, err = ParseFilter(strings.Repeat(`id pr or `, MaxFilterTerms) + `id pr`)
+    assert.EqualError(t, err, `Filter has more than 32 comparisons at position 289 near "id"`)
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"user-storage/models"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+    SearchFullText = "fulltext"
+    SearchMemory   = "memory"
+)
+
+// fullTextMinToken is InnoDB's default innodb_ft_min_token_size. Shorter
+// terms are never indexed, so queries need at least one term this long to be
+// looked up in the index rather than scanned.
+const fullTextMinToken = 3
+
+// searchBackend picks how candidates are found, set by SEARCH_BACKEND. By
+// default MySQL uses its FULLTEXT index and other databases are scanned.
+func searchBackend(db *gorm.DB) string {
+    switch backend := strings.ToLower(os.Getenv("SEARCH_BACKEND")); backend {
+    case SearchFullText, SearchMemory:
+        return backend
+    }
+    if db.Dialector.Name() == "mysql" {
+        return SearchFullText
+    }
+    return SearchMemory
+}
+
+var foldAccents = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// fold lowercases s and strips its accents, so "Zoë" matches "zoe".
+func fold(s string) string {
+    folded, _, err := transform.String(foldAccents, s)
+    if err != nil {
+        folded = s
+    }
+    return strings.ToLower(folded)
+}
+
+// searchTokens splits s into folded words, breaking on anything that is not
+// a letter or digit, such as the @ and dots of an email.
+func searchTokens(s string) []string {
+    return strings.FieldsFunc(fold(s), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+}
+
+func trigrams(token string) map[string]bool {
+    padded := []rune("  " + token + " ")
+    grams := make(map[string]bool, len(padded))
+    for i := 0; i+3 <= len(padded); i++ {
+        grams[string(padded[i:i+3])] = true
+    }
+    return grams
+}
+
+// similarity is the Jaccard index of the trigrams of a and b.
+func similarity(a, b string) float64 {
+    ga, gb := trigrams(a), trigrams(b)
+    shared := 0
+    for gram := range ga {
+        if gb[gram] {
+            shared++
+        }
+    }
+    return float64(shared) / float64(len(ga)+len(gb)-shared)
+}
+
+// termScore rates how well a query term matches a word: exactly, as a
+// prefix, inside it, or failing that as a likely misspelling.
+func termScore(term, word string) float64 {
+    switch {
+    case term == word:
+        return 3
+    case strings.HasPrefix(word, term):
+        return 2
+    case len(term) >= fullTextMinToken && strings.Contains(word, term):
+        return 1
+    }
+    if s := similarity(term, word); s >= 0.4 {
+        return s
+    }
+    return 0
+}
+
+type searchField struct {
+    Words  []string
+    Weight float64
+}
+
+// scoreUser ranks user against the query terms. Every term has to match
+// some word; whole names and emails score extra. Fields the caller's
+// redaction hides are not searched, so they cannot be probed for.
+func scoreUser(query string, terms []string, user models.User, redaction Redaction) float64 {
+    var fields []searchField
+    if _, hidden := redaction["firstName"]; !hidden {
+        fields = append(fields, searchField{searchTokens(user.FirstName), 1})
+    }
+    if _, hidden := redaction["lastName"]; !hidden {
+        fields = append(fields, searchField{searchTokens(user.LastName), 1})
+    }
+    if _, hidden := redaction["email"]; !hidden {
+        fields = append(fields, searchField{searchTokens(user.Email), 0.7})
+    }
+
+    score := 0.0
+    for _, term := range terms {
+        best := 0.0
+        for _, field := range fields {
+            for _, word := range field.Words {
+                if s := termScore(term, word) * field.Weight; s > best {
+                    best = s
+                }
+            }
+        }
+        if best == 0 {
+            return 0
+        }
+        score += best
+    }
+
+    phrase := strings.Join(terms, " ")
+    _, firstHidden := redaction["firstName"]
+    _, lastHidden := redaction["lastName"]
+    if !firstHidden && !lastHidden {
+        first, last := strings.Join(searchTokens(user.FirstName), " "), strings.Join(searchTokens(user.LastName), " ")
+        // Either name order, as family names come first in some cultures
+        for _, full := range []string{first + " " + last, last + " " + first} {
+            if full == phrase {
+                score += 4
+                break
+            } else if strings.HasPrefix(full, phrase) {
+                score += 2
+                break
+            }
+        }
+    }
+    if _, hidden := redaction["email"]; !hidden && fold(strings.TrimSpace(query)) == fold(user.Email) {
+        score += 6
+    }
+    return score
+}
+
+// SearchUsers returns up to limit visible users matching query, best first,
+// scored across first name, last name, full name and email. query needs a
+// term of at least fullTextMinToken letters or digits. Candidates come from the
+// FULLTEXT index on MySQL, otherwise every visible user is scanned.
+func (t *UserService) SearchUsers(query string, limit int, redaction Redaction) (*[]models.UserSearchResult, int, error) {
+    terms := searchTokens(query)
+    var indexed []string
+    for _, term := range terms {
+        if len([]rune(term)) >= fullTextMinToken {
+            indexed = append(indexed, term+"*")
+        }
+    }
+    if len(indexed) == 0 {
+        return nil, http.StatusBadRequest, errors.New("Search query must contain a word of at least " + strconv.Itoa(fullTextMinToken) + " letters or digits")
+    }
+    if limit < 1 || limit > MaxPageSize() {
+        return nil, http.StatusBadRequest, errors.New("Limit must be between 1 and " + strconv.Itoa(MaxPageSize()))
+    }
+
+    var results []models.UserSearchResult
+    seen := map[string]bool{}
+    collect := func(users []models.User) {
+        for _, user := range users {
+            if seen[user.Id] {
+                continue
+            }
+            seen[user.Id] = true
+            if score := scoreUser(query, terms, user, redaction); score > 0 {
+                results = append(results, models.UserSearchResult{User: user, Score: score})
+            }
+        }
+    }
+
+    if searchBackend(t.DB) == SearchFullText {
+        // Every indexed term is required first, so partial matches cannot
+        // crowd out full ones. Misspelt terms miss the index, so when full
+        // matches run short any term will do. Extra candidates are fetched as
+        // the ranking below can reorder them.
+        for _, against := range []string{"+" + strings.Join(indexed, " +"), strings.Join(indexed, " ")} {
+            if len(results) >= limit {
+                break
+            }
+            users, err := t.fullTextCandidates(against, limit*5)
+            if err != nil {
+                return nil, http.StatusInternalServerError, err
+            }
+            collect(users)
+        }
+    } else {
+        var batch []models.User
+        err := t.visible(t.DB).FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+            collect(batch)
+            return nil
+        }).Error
+        if err != nil {
+            return nil, http.StatusInternalServerError, err
+        }
+    }
+
+    sort.SliceStable(results, func(i, j int) bool {
+        a, b := results[i], results[j]
+        if a.Score != b.Score {
+            return a.Score > b.Score
+        }
+        if a.User.LastName != b.User.LastName {
+            return a.User.LastName < b.User.LastName
+        }
+        if a.User.FirstName != b.User.FirstName {
+            return a.User.FirstName < b.User.FirstName
+        }
+        return a.User.Id < b.User.Id
+    })
+    if len(results) > limit {
+        results = results[:limit]
+    }
+    if results == nil {
+        results = []models.UserSearchResult{}
+    }
+
+    return &results, http.StatusOK, nil
+}
+
+// fullTextCandidates returns up to limit visible users matching against, a
+// boolean mode FULLTEXT query, most relevant first.
+func (t *UserService) fullTextCandidates(against string, limit int) ([]models.User, error) {
+    const match = "MATCH(first_name, last_name, email) AGAINST (? IN BOOLEAN MODE)"
+    var users []models.User
+    err := t.visible(t.DB).Where(match, against).
+        Clauses(clause.OrderBy{Expression: clause.Expr{SQL: match + " DESC", Vars: []interface{}{against}, WithoutParentheses: true}}).
+        Limit(limit).Find(&users).Error
+    return users, err
+}
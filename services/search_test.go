@@ -0,0 +1,122 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchUsers(t *testing.T) {
+    t.Setenv("SEARCH_BACKEND", SearchMemory)
+    userService := NewUserService(gormDB)
+
+    rows := func() *sqlmock.Rows {
+        return sqlmock.NewRows([]string{"id", "first_name", "last_name", "email"}).
+            AddRow("1", "Wei Ming", "Tan", "weiming.tan@bank.com").
+            AddRow("2", "Zoë", "Weil", "zoe@example.com").
+            AddRow("3", "Wei", "Lim", "wlim@example.com").
+            AddRow("4", "Marilyn", "Monroe", "marilyn@monroe.com")
+    }
+    scan := regexp.QuoteMeta("SELECT * FROM `users` WHERE `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT 500")
+
+    // Words match anywhere in the name, exact words first
+    mock.ExpectQuery(scan).WillReturnRows(rows())
+    results, statusCode, err := userService.SearchUsers("wei", 10, nil)
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    var ids []string
+    for _, result := range *results {
+        ids = append(ids, result.User.Id)
+    }
+    assert.Equal(t, []string{"3", "1", "2"}, ids)
+
+    // The full name in either order outranks a partial match, accents aside
+    mock.ExpectQuery(scan).WillReturnRows(rows())
+    results, _, err = userService.SearchUsers("Tan Wei Ming", 10, nil)
+
+    assert.NoError(t, err)
+    assert.Len(t, *results, 1)
+    assert.Equal(t, "1", (*results)[0].User.Id)
+
+    mock.ExpectQuery(scan).WillReturnRows(rows())
+    results, _, err = userService.SearchUsers("zoe", 10, nil)
+
+    assert.NoError(t, err)
+    assert.Equal(t, "2", (*results)[0].User.Id)
+
+    // Emails the caller cannot see are not searched
+    mock.ExpectQuery(scan).WillReturnRows(rows())
+    results, _, err = userService.SearchUsers("bank", 10, Redaction{"email": PIIOmit})
+
+    assert.NoError(t, err)
+    assert.Empty(t, *results)
+
+    _, statusCode, err = userService.SearchUsers("%_", 10, nil)
+
+    assert.Error(t, err)
+    assert.Equal(t, http.StatusBadRequest, statusCode)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchUsers_FullText(t *testing.T) {
+    t.Setenv("SEARCH_BACKEND", SearchFullText)
+    userService := NewUserService(gormDB)
+    search := regexp.QuoteMeta("SELECT * FROM `users` WHERE MATCH(first_name, last_name, email) AGAINST (? IN BOOLEAN MODE) AND `users`.`deleted_at` IS NULL ORDER BY MATCH(first_name, last_name, email) AGAINST (? IN BOOLEAN MODE) DESC LIMIT 10")
+    columns := []string{"id", "first_name", "last_name", "email"}
+
+    // Terms shorter than the index's minimum token size are left to ranking.
+    // Every indexed term is required, then any will do while results are short.
+    mock.ExpectQuery(search).
+        WithArgs("+wei*", "+wei*").
+        WillReturnRows(sqlmock.NewRows(columns).
+            AddRow("3", "Wei", "Lim", "wlim@example.com").
+            AddRow("1", "Wei Ming", "Tan", "weiming.tan@bank.com"))
+    mock.ExpectQuery(search).
+        WithArgs("wei*", "wei*").
+        WillReturnRows(sqlmock.NewRows(columns).
+            AddRow("3", "Wei", "Lim", "wlim@example.com"))
+
+    results, statusCode, err := userService.SearchUsers("li wei", 2, nil)
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Len(t, *results, 1)
+    assert.Equal(t, "3", (*results)[0].User.Id)
+
+    // Misspelt terms miss the index, but still rank once any term matches
+    mock.ExpectQuery(search).
+        WithArgs("+wei* +tann*", "+wei* +tann*").
+        WillReturnRows(sqlmock.NewRows(columns))
+    mock.ExpectQuery(search).
+        WithArgs("wei* tann*", "wei* tann*").
+        WillReturnRows(sqlmock.NewRows(columns).
+            AddRow("1", "Wei Ming", "Tan", "weiming.tan@bank.com"))
+
+    results, _, err = userService.SearchUsers("wei tann", 2, nil)
+
+    assert.NoError(t, err)
+    assert.Len(t, *results, 1)
+    assert.Equal(t, "1", (*results)[0].User.Id)
+
+    // Full matches alone are enough when they fill the limit
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE MATCH(first_name, last_name, email) AGAINST (? IN BOOLEAN MODE) AND `users`.`deleted_at` IS NULL ORDER BY MATCH(first_name, last_name, email) AGAINST (? IN BOOLEAN MODE) DESC LIMIT 5")).
+        WithArgs("+wei*", "+wei*").
+        WillReturnRows(sqlmock.NewRows(columns).
+            AddRow("3", "Wei", "Lim", "wlim@example.com"))
+
+    results, _, err = userService.SearchUsers("wei", 1, nil)
+
+    assert.NoError(t, err)
+    assert.Len(t, *results, 1)
+
+    // Queries of short terms alone would scan every user
+    _, statusCode, err = userService.SearchUsers("a li", 2, nil)
+
+    assert.EqualError(t, err, "Search query must contain a word of at least 3 letters or digits")
+    assert.Equal(t, http.StatusBadRequest, statusCode)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StampSortKeys maps the sort keys listings accept to their columns.
+var StampSortKeys = map[string]string{
+    "createdAt": "created_at",
+    "updatedAt": "updated_at",
+    "createdBy": "created_by",
+    "updatedBy": "updated_by",
+}
+
+// StampFilter narrows and orders a listing by models.Stamps. Zero fields do
+// not filter. Sort holds keys of SortKeys accepted by ParseSort, each
+// prefixed with "-" to descend. SortKeys defaults to StampSortKeys.
+type StampFilter struct {
+    CreatedBy     string
+    UpdatedBy     string
+    CreatedAfter  *time.Time
+    CreatedBefore *time.Time
+    UpdatedAfter  *time.Time
+    UpdatedBefore *time.Time
+    Sort          []string
+    SortKeys      map[string]string
+}
+
+// ParseSort splits a comma separated sort parameter such as
+// "-updatedAt,createdBy", refusing keys outside keys.
+func ParseSort(raw string, keys map[string]string) ([]string, error) {
+    var sort []string
+    for _, key := range strings.Split(raw, ",") {
+        if key = strings.TrimSpace(key); key == "" {
+            continue
+        }
+        if _, ok := keys[strings.TrimPrefix(key, "-")]; !ok {
+            return nil, fmt.Errorf("Cannot sort by %q", key)
+        }
+        sort = append(sort, key)
+    }
+    return sort, nil
+}
+
+// sortClause orders by a key ParseSort accepted from keys, flipping its
+// direction when reverse is set.
+func sortClause(key string, keys map[string]string, reverse bool) string {
+    column := keys[strings.TrimPrefix(key, "-")]
+    if strings.HasPrefix(key, "-") != reverse {
+        return column + " DESC"
+    }
+    return column
+}
+
+// Apply adds the filter's conditions and ordering to query.
+func (f StampFilter) Apply(query *gorm.DB) *gorm.DB {
+    if f.CreatedBy != "" {
+        query = query.Where("created_by = ?", f.CreatedBy)
+    }
+    if f.UpdatedBy != "" {
+        query = query.Where("updated_by = ?", f.UpdatedBy)
+    }
+    if f.CreatedAfter != nil {
+        query = query.Where("created_at >= ?", *f.CreatedAfter)
+    }
+    if f.CreatedBefore != nil {
+        query = query.Where("created_at < ?", *f.CreatedBefore)
+    }
+    if f.UpdatedAfter != nil {
+        query = query.Where("updated_at >= ?", *f.UpdatedAfter)
+    }
+    if f.UpdatedBefore != nil {
+        query = query.Where("updated_at < ?", *f.UpdatedBefore)
+    }
+    keys := f.SortKeys
+    if keys == nil {
+        keys = StampSortKeys
+    }
+    for _, key := range f.Sort {
+        query = query.Order(sortClause(key, keys, false))
+    }
+    return query
+}
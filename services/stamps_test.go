@@ -0,0 +1,28 @@
+package services
+
+import (
+	"testing"
+	"time"
+	"user-storage/models"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestStampFilter(t *testing.T) {
+    sort, err := ParseSort("-updatedAt, createdBy", StampSortKeys)
+    assert.NoError(t, err)
+    assert.Equal(t, []string{"-updatedAt", "createdBy"}, sort)
+
+    _, err = ParseSort("password", StampSortKeys)
+    assert.EqualError(t, err, `Cannot sort by "password"`)
+
+    after := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+    filter := StampFilter{CreatedBy: "1", UpdatedAfter: &after, Sort: sort}
+
+    var roles []models.Role
+    stmt := filter.Apply(gormDB.Session(&gorm.Session{DryRun: true})).Find(&roles).Statement
+
+    assert.Equal(t, "SELECT * FROM `roles` WHERE created_by = ? AND updated_at >= ? ORDER BY updated_at DESC,created_by", stmt.SQL.String())
+    assert.Equal(t, []interface{}{"1", after}, stmt.Vars)
+}
@@ -1,61 +1,134 @@
 package services
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"net/http"
+	"time"
 	"user-storage/models"
 
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var validate = validator.New()
 
 type UserService struct {
-    DB *gorm.DB
+    DB             *gorm.DB
+    Scope          *UserScope
+    IncludeDeleted bool
+    // Redaction is how the caller sees users, for the few answers that
+    // reveal more than the users returned. nil shows everything.
+    Redaction      Redaction
 }
 
 func NewUserService(db *gorm.DB) *UserService {
     return &UserService{DB: db}
 }
 
+// WithScope returns a copy of the service whose reads only return users
+// within scope. A nil scope leaves reads unrestricted.
+func (t *UserService) WithScope(scope *UserScope) *UserService {
+    scoped := *t
+    scoped.Scope = scope
+    return &scoped
+}
 
-func (t *UserService) GetAllUsers(role int, id, name, email string) (*[]models.User, int, error) {
-    var users []models.User
+// WithDeleted returns a copy of the service whose reads also return
+// soft-deleted users.
+func (t *UserService) WithDeleted() *UserService {
+    withDeleted := *t
+    withDeleted.IncludeDeleted = true
+    return &withDeleted
+}
 
-    query := t.DB
-    if id != "" {
-        query = query.Where("id LIKE ?", fmt.Sprint(id,"%"))
-    }
-    if role != -1 && role != 0{
-        query = query.Where("role = ?", role)
-    } else if role == 0 {
-        query = query.Where("role IS NULL")
+// WithRedaction returns a copy of the service answering as a caller who sees
+// users through redaction.
+func (t *UserService) WithRedaction(redaction Redaction) *UserService {
+    redacted := *t
+    redacted.Redaction = redaction
+    return &redacted
+}
+
+// WithContext returns a copy of the service whose queries run with ctx, which
+// also stamps its writes with the actor set by models.WithActor.
+func (t *UserService) WithContext(ctx context.Context) *UserService {
+    withContext := *t
+    withContext.DB = t.DB.WithContext(ctx)
+    return &withContext
+}
+
+// visible applies the service's UserScope to a users query, and lets
+// soft-deleted users through when IncludeDeleted is set.
+func (t *UserService) visible(query *gorm.DB) *gorm.DB {
+    if t.IncludeDeleted {
+        query = query.Unscoped()
     }
-    if name != "" {
-        query = query.Where("first_name LIKE ? OR last_name LIKE ?", fmt.Sprint(name,"%"), fmt.Sprint(name,"%"))
+    if t.Scope == nil {
+        return query
     }
-    if email != "" {
-        query = query.Where("email LIKE ?", fmt.Sprint(email,"%"))
+    switch {
+    case len(t.Scope.RoleIds) > 0 && t.Scope.Unassigned:
+        return query.Where("id IN (?) OR id NOT IN (?)", usersWithActiveRoles(t.DB, t.Scope.RoleIds...), usersWithActiveRoles(t.DB))
+    case t.Scope.Unassigned:
+        return query.Where("id NOT IN (?)", usersWithActiveRoles(t.DB))
+    case len(t.Scope.RoleIds) > 0:
+        return query.Where("id IN (?)", usersWithActiveRoles(t.DB, t.Scope.RoleIds...))
     }
+    // A scope that admits nobody
+    return query.Where("1 = 0")
+}
 
-    if err := query.Find(&users).Error; err != nil {
-        return nil, http.StatusInternalServerError, err
+
+// UserFilter holds the filters user listings accept. Role -1 does not filter
+// and 0 matches users without an active role. Expression is a parsed SCIM
+// filter, see ParseFilter.
+type UserFilter struct {
+    Role       int
+    Id         string
+    Name       string
+    Email      string
+    Stamps     StampFilter
+    Expression FilterNode
+}
+
+// filtered returns the visible users matching filter, without ordering.
+func (t *UserService) filtered(filter UserFilter) *gorm.DB {
+    query := t.visible(t.DB)
+    if filter.Id != "" {
+        query = query.Where("id LIKE ?", escapeLike(filter.Id)+"%")
     }
-	
-    return &users, http.StatusOK, nil
+    if filter.Role != -1 && filter.Role != 0{
+        query = query.Where("id IN (?)", usersWithActiveRoles(t.DB, filter.Role))
+    } else if filter.Role == 0 {
+        query = query.Where("id NOT IN (?)", usersWithActiveRoles(t.DB))
+    }
+    if filter.Name != "" {
+        query = query.Where("first_name LIKE ? OR last_name LIKE ?", escapeLike(filter.Name)+"%", escapeLike(filter.Name)+"%")
+    }
+    if filter.Email != "" {
+        query = query.Where("email LIKE ?", escapeLike(filter.Email)+"%")
+    }
+    if filter.Expression != nil {
+        query = query.Where(filter.Expression.expression(t.DB))
+    }
+    stamps := filter.Stamps
+    stamps.Sort = nil
+    return stamps.Apply(query)
 }
 
-func (t *UserService) GetPaginatedUsers(page, pageSize int) (*[]models.User, int, error) {
+func (t *UserService) GetAllUsers(filter UserFilter) (*[]models.User, int, error) {
     var users []models.User
 
-    offset := (page - 1) * pageSize
-    err := t.DB.Offset(offset).Limit(pageSize).Find(&users)
-    if err.Error != nil {
-        return nil, http.StatusInternalServerError, err.Error
+    query := t.filtered(filter)
+    for _, key := range filter.Stamps.Sort {
+        query = query.Order(sortClause(key, UserSortKeys, false))
     }
-
+    if err := query.Find(&users).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+	
     return &users, http.StatusOK, nil
 }
 
@@ -64,7 +137,7 @@ func (t *UserService) GetUserByID(id string) (*models.User, int, error) {
 	if id == "" {
 		return nil, http.StatusBadRequest, errors.New("User ID cannot be empty")
 	}
-    err := t.DB.First(&user, "id = ?", id).Error
+    err := t.visible(t.DB).First(&user, "id = ?", id).Error
 	if err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return nil, http.StatusNotFound, errors.New("User ID is not found")
@@ -76,33 +149,66 @@ func (t *UserService) GetUserByID(id string) (*models.User, int, error) {
 }
 
 func (t *UserService) AddUser(user *models.User) (*models.User, int, error) {
+    if err := normalizeUserEmail(user); err != nil {
+        return nil, http.StatusBadRequest, err
+    }
     if err := validate.Struct(user); err != nil {
         return nil, http.StatusBadRequest, err
     }
 
     tx := t.DB.Begin()
+    if code, err := t.checkEmailAvailable(tx, user.Email, ""); err != nil {
+        tx.Rollback()
+        return nil, code, err
+    }
     err := tx.Create(&user).Error
     if err != nil {
         tx.Rollback()
+        if errors.Is(err, gorm.ErrDuplicatedKey) {
+            code, err := t.duplicateEmail(t.DB, user.Email, user.Id)
+            return nil, code, err
+        }
         return nil, http.StatusInternalServerError, err
     }
+    if user.Role != nil {
+        if err := assignRole(tx, user.Id, *user.Role); err != nil {
+            tx.Rollback()
+            return nil, http.StatusInternalServerError, err
+        }
+    }
     tx.Commit()
     
     return user, http.StatusCreated, nil
 }
 
 func (t *UserService) UpdateUserById(user *models.User, id string) (*models.User, int, error) {
+    return t.updateUser(user, id, false)
+}
+
+// ReplaceUserById writes every field of user, unlike UpdateUserById which
+// leaves a nil role alone. A nil role clears the primary role.
+func (t *UserService) ReplaceUserById(user *models.User, id string) (*models.User, int, error) {
+    return t.updateUser(user, id, true)
+}
+
+func (t *UserService) updateUser(user *models.User, id string, replace bool) (*models.User, int, error) {
 	if id == "" {
 		return nil, http.StatusBadRequest, errors.New("User ID cannot be empty")
 	}
 
+    if err := normalizeUserEmail(user); err != nil {
+        return nil, http.StatusBadRequest, err
+    }
     if err := validate.Struct(user); err != nil {
         return nil, http.StatusBadRequest, err
     }
 
+    // Locked, so a role change is checked for conflicts against the roles
+    // held once any concurrent assignment has committed
     tx := t.DB.Begin()
     existingUser := models.User{}
-    if err := tx.Where("id = ?", id).First(&existingUser).Error; err != nil {
+    if err := t.visible(tx).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&existingUser).Error; err != nil {
+        tx.Rollback()
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return nil, http.StatusNotFound, errors.New("User not found with given ID")
         } else {
@@ -112,18 +218,90 @@ func (t *UserService) UpdateUserById(user *models.User, id string) (*models.User
 
     user.Id = id
 
+    // A version in user is the one the caller read, and the write only goes
+    // ahead while it is still current
+    expected := existingUser.Version
+    if user.Version != 0 && user.Version != expected {
+        tx.Rollback()
+        return nil, http.StatusPreconditionFailed, errors.New("User was modified by someone else")
+    }
+    user.Version = expected + 1
+
+    if user.Email != existingUser.Email {
+        if code, err := t.checkEmailAvailable(tx, user.Email, id); err != nil {
+            tx.Rollback()
+            return nil, code, err
+        }
+    }
+
+    roleChanged := user.Role != nil && (existingUser.Role == nil || *existingUser.Role != *user.Role)
+    roleCleared := replace && user.Role == nil && existingUser.Role != nil
+    if roleChanged {
+        held, err := heldRoleIds(tx, id)
+        if err != nil {
+            tx.Rollback()
+            return nil, http.StatusInternalServerError, err
+        }
+        roleIds := []uint{*user.Role}
+        for _, roleId := range held {
+            if existingUser.Role == nil || roleId != *existingUser.Role {
+                roleIds = append(roleIds, roleId)
+            }
+        }
+        if code, err := checkRoleConflicts(tx, roleIds); err != nil {
+            tx.Rollback()
+            return nil, code, err
+        }
+    }
+
     // Update the user's data
-    err := tx.Model(models.User{Id: id}).Updates(&user).Error
+    query := tx.Model(&models.User{Id: id}).Where("version = ?", expected).Omit("DeletedAt")
+    if replace {
+        query = query.Select("FirstName", "LastName", "Email", "Role", "Version")
+    }
+    result := query.Updates(&user)
 
-    if err != nil {
+    if err := result.Error; err != nil {
         tx.Rollback()
+        if errors.Is(err, gorm.ErrDuplicatedKey) {
+            code, err := t.duplicateEmail(t.DB, user.Email, id)
+            return nil, code, err
+        }
         return nil, http.StatusInternalServerError, err
     }
+    if result.RowsAffected == 0 {
+        tx.Rollback()
+        return nil, http.StatusPreconditionFailed, errors.New("User was modified by someone else")
+    }
+
+    // Replacing the primary role also replaces its user_roles assignment
+    if roleCleared {
+        err := tx.Where("user_id = ? AND role_id = ?", id, *existingUser.Role).Delete(&models.UserRole{}).Error
+        if err != nil {
+            tx.Rollback()
+            return nil, http.StatusInternalServerError, err
+        }
+    }
+    if roleChanged {
+        if existingUser.Role != nil {
+            err := tx.Where("user_id = ? AND role_id = ?", id, *existingUser.Role).Delete(&models.UserRole{}).Error
+            if err != nil {
+                tx.Rollback()
+                return nil, http.StatusInternalServerError, err
+            }
+        }
+        if err := assignRole(tx, id, *user.Role); err != nil {
+            tx.Rollback()
+            return nil, http.StatusInternalServerError, err
+        }
+    }
     tx.Commit()
 
     return user, http.StatusOK, nil
 }
 
+// DeleteUserById soft-deletes the user. It keeps its email, which stays
+// unique, until it is purged.
 func (t *UserService) DeleteUserById (id string) (*models.User, int, error) {
     if id == "" {
         return nil, http.StatusBadRequest, errors.New("User ID cannot be empty")
@@ -145,6 +323,7 @@ func (t *UserService) DeleteUserById (id string) (*models.User, int, error) {
  //        return nil, http.StatusInternalServerError, err
 	// }
 
+    // Role assignments are kept for a restore, and removed by the purge
     err = tx.Where("id = ?", id).Delete(existingUser).Error
     if err != nil {
         tx.Rollback()
@@ -155,9 +334,81 @@ func (t *UserService) DeleteUserById (id string) (*models.User, int, error) {
     return existingUser, http.StatusOK, nil
 }
 
+// RestoreUserById brings back a soft-deleted user within the service's scope,
+// with the roles it held. Its primary role is assigned again if the role still
+// exists, otherwise it is cleared. Roles that have come to conflict while the
+// user was deleted are refused with 409.
+func (t *UserService) RestoreUserById(id string) (*models.User, int, error) {
+    if id == "" {
+        return nil, http.StatusBadRequest, errors.New("User ID cannot be empty")
+    }
+
+    tx := t.DB.Begin()
+    var existingUser models.User
+    err := t.visible(tx.Unscoped()).Clauses(clause.Locking{Strength: "UPDATE"}).First(&existingUser, "id = ?", id).Error
+    if err != nil {
+        tx.Rollback()
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, http.StatusNotFound, errors.New("User ID is not found")
+        }
+        return nil, http.StatusInternalServerError, err
+    }
+    if !existingUser.DeletedAt.Valid {
+        tx.Rollback()
+        return nil, http.StatusConflict, errors.New("User is not deleted")
+    }
+
+    if existingUser.Role != nil {
+        var count int64
+        if err := tx.Model(&models.Role{}).Where("id = ?", *existingUser.Role).Count(&count).Error; err != nil {
+            tx.Rollback()
+            return nil, http.StatusInternalServerError, err
+        }
+        if count == 0 {
+            existingUser.Role = nil
+        }
+    }
+
+    held, err := heldRoleIds(tx, id)
+    if err != nil {
+        tx.Rollback()
+        return nil, http.StatusInternalServerError, err
+    }
+    if existingUser.Role != nil {
+        held = append(held, *existingUser.Role)
+    }
+    if code, err := checkRoleConflicts(tx, held); err != nil {
+        tx.Rollback()
+        return nil, code, err
+    }
+
+    expected := existingUser.Version
+    existingUser.Version = expected + 1
+    existingUser.DeletedAt = gorm.DeletedAt{}
+    result := tx.Unscoped().Model(&models.User{Id: id}).Where("version = ?", expected).
+        Select("Role", "Version", "DeletedAt").Updates(&existingUser)
+    if result.Error != nil {
+        tx.Rollback()
+        return nil, http.StatusInternalServerError, result.Error
+    }
+    if result.RowsAffected == 0 {
+        tx.Rollback()
+        return nil, http.StatusPreconditionFailed, errors.New("User was modified by someone else")
+    }
+    if existingUser.Role != nil {
+        if err := assignRole(tx, id, *existingUser.Role); err != nil {
+            tx.Rollback()
+            return nil, http.StatusInternalServerError, err
+        }
+    }
+    tx.Commit()
+
+    return &existingUser, http.StatusOK, nil
+}
+
 func (t *UserService) GetUsersWithRole(roles []int) (*[]models.User, int, error) {
 	var users []models.User
-    err := t.DB.Where("role IN ?", roles).Find(&users).Error
+    err := t.visible(t.DB).Where("id IN (?)", usersWithActiveRoles(t.DB, roles...)).Find(&users).Error
     if err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return nil, http.StatusNotFound, errors.New("Cannot find users with given roles")
@@ -168,3 +419,118 @@ func (t *UserService) GetUsersWithRole(roles []int) (*[]models.User, int, error)
 
     return &users, http.StatusOK, nil
 }
+
+// GetUserRoles returns every role currently in effect for the user.
+func (t *UserService) GetUserRoles(id string) (*[]models.Role, int, error) {
+    if _, code, err := t.GetUserByID(id); err != nil {
+        return nil, code, err
+    }
+
+    roles := []models.Role{}
+    err := t.DB.Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+        Scopes(ActiveRoleAssignments).
+        Where("user_roles.user_id = ?", id).
+        Order("roles.id").
+        Find(&roles).Error
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+
+    return &roles, http.StatusOK, nil
+}
+
+// roleUpdate sets the primary role to role, or clears it when nil. Version is
+// bumped like any other write to a user, so ETags read before go stale.
+func roleUpdate(role interface{}) map[string]interface{} {
+    return map[string]interface{}{"role": role, "version": gorm.Expr("version + 1")}
+}
+
+// AddUserRole assigns an additional role to the user, optionally bounded by
+// validFrom and validUntil. Assigning a role the user already holds replaces
+// its validity window. The first permanent role a user receives also becomes
+// their primary role.
+func (t *UserService) AddUserRole(id string, assignment models.UserRole) (*models.UserRole, int, error) {
+    if assignment.ValidFrom != nil && assignment.ValidUntil != nil && !assignment.ValidUntil.After(*assignment.ValidFrom) {
+        return nil, http.StatusBadRequest, errors.New("validUntil must be after validFrom")
+    }
+    if assignment.ValidUntil != nil && !assignment.ValidUntil.After(time.Now()) {
+        return nil, http.StatusBadRequest, errors.New("validUntil must be in the future")
+    }
+
+    var role models.Role
+    if err := t.DB.First(&role, "id = ?", assignment.RoleId).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, http.StatusNotFound, errors.New("Role ID is not found")
+        }
+        return nil, http.StatusInternalServerError, err
+    }
+
+    // Locking the user makes concurrent assignments check for conflicts one
+    // after another, each seeing the roles the other assigned
+    tx := t.DB.Begin()
+    var user models.User
+    err := t.visible(tx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", id).Error
+    if err != nil {
+        tx.Rollback()
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, http.StatusNotFound, errors.New("User ID is not found")
+        }
+        return nil, http.StatusInternalServerError, err
+    }
+
+    held, err := heldRoleIds(tx, id)
+    if err != nil {
+        tx.Rollback()
+        return nil, http.StatusInternalServerError, err
+    }
+    if code, err := checkRoleConflicts(tx, append(held, assignment.RoleId)); err != nil {
+        tx.Rollback()
+        return nil, code, err
+    }
+
+    assignment.UserId = id
+    err = tx.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"valid_from", "valid_until"})}).
+        Create(&assignment).Error
+    if err != nil {
+        tx.Rollback()
+        return nil, http.StatusInternalServerError, err
+    }
+    if user.Role == nil && assignment.ValidFrom == nil && assignment.ValidUntil == nil {
+        if err := tx.Model(&models.User{Id: id}).Updates(roleUpdate(assignment.RoleId)).Error; err != nil {
+            tx.Rollback()
+            return nil, http.StatusInternalServerError, err
+        }
+    }
+    tx.Commit()
+
+    return &assignment, http.StatusCreated, nil
+}
+
+// RemoveUserRole revokes a role from the user, clearing the primary role if it
+// was the one removed.
+func (t *UserService) RemoveUserRole(id string, roleId uint) (*models.UserRole, int, error) {
+    user, code, err := t.GetUserByID(id)
+    if err != nil {
+        return nil, code, err
+    }
+
+    tx := t.DB.Begin()
+    result := tx.Where("user_id = ? AND role_id = ?", id, roleId).Delete(&models.UserRole{})
+    if result.Error != nil {
+        tx.Rollback()
+        return nil, http.StatusInternalServerError, result.Error
+    }
+    if result.RowsAffected == 0 {
+        tx.Rollback()
+        return nil, http.StatusNotFound, errors.New("User does not have the given role")
+    }
+    if user.Role != nil && *user.Role == roleId {
+        if err := tx.Model(&models.User{Id: id}).Updates(roleUpdate(nil)).Error; err != nil {
+            tx.Rollback()
+            return nil, http.StatusInternalServerError, err
+        }
+    }
+    tx.Commit()
+
+    return &models.UserRole{UserId: id, RoleId: roleId}, http.StatusOK, nil
+}
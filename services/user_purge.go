@@ -0,0 +1,67 @@
+package services
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"user-storage/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// UserRetention is how long soft-deleted users are kept before they are
+// purged, from USER_RETENTION_DAYS and 30 days by default.
+func UserRetention() time.Duration {
+    days := 30
+    if raw := os.Getenv("USER_RETENTION_DAYS"); raw != "" {
+        if parsed, err := strconv.Atoi(raw); err == nil && parsed >= 0 {
+            days = parsed
+        }
+    }
+    return time.Duration(days) * 24 * time.Hour
+}
+
+// PurgeDeletedUsers permanently deletes users that were soft-deleted before
+// cutoff, along with the role assignments they kept, and writes an audit event
+// for each.
+func (t *UserService) PurgeDeletedUsers(cutoff time.Time) (*[]models.User, int, error) {
+    var purged []models.User
+    if err := t.DB.Unscoped().Where("deleted_at < ?", cutoff).Find(&purged).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    if len(purged) == 0 {
+        return &purged, http.StatusOK, nil
+    }
+
+    ids := make([]string, 0, len(purged))
+    for _, user := range purged {
+        ids = append(ids, user.Id)
+    }
+
+    tx := t.DB.Begin()
+    if err := tx.Where("user_id IN ?", ids).Delete(&models.UserRole{}).Error; err != nil {
+        tx.Rollback()
+        return nil, http.StatusInternalServerError, err
+    }
+    // Re-check deleted_at so a user restored in the meantime survives
+    err := tx.Unscoped().Where("id IN ? AND deleted_at < ?", ids, cutoff).Delete(&models.User{}).Error
+    if err != nil {
+        tx.Rollback()
+        return nil, http.StatusInternalServerError, err
+    }
+    tx.Commit()
+
+    for _, user := range purged {
+        log.WithFields(log.Fields{
+            "ACTOR":  "system",
+            "ACTION": "purge user",
+            "USER_DETAILS": log.Fields{
+                "id": user.Id,
+            },
+            "DELETED_AT": user.DeletedAt.Time,
+        }).Info("USER PURGED")
+    }
+
+    return &purged, http.StatusOK, nil
+}
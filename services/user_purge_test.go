@@ -0,0 +1,37 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeDeletedUsers(t *testing.T) {
+    userService := NewUserService(gormDB)
+    cutoff := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE deleted_at < ?")).
+        WithArgs(cutoff).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "deleted_at"}).
+            AddRow("7", "John7", "Doe", "john7@example.com", cutoff.Add(-time.Hour)))
+    mock.ExpectBegin()
+    mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `user_roles` WHERE user_id IN (?)")).
+        WithArgs("7").
+        WillReturnResult(sqlmock.NewResult(0, 0))
+    mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `users` WHERE id IN (?) AND deleted_at < ?")).
+        WithArgs("7", cutoff).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
+
+    purged, statusCode, err := userService.PurgeDeletedUsers(cutoff)
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Len(t, *purged, 1)
+    assert.Equal(t, "7", (*purged)[0].Id)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
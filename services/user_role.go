@@ -0,0 +1,90 @@
+package services
+
+import (
+	"net/http"
+	"time"
+	"user-storage/models"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// currentRoleAssignments limits a user_roles query to assignments between
+// their validFrom and validUntil.
+func currentRoleAssignments(db *gorm.DB) *gorm.DB {
+    now := time.Now()
+    return db.Where("(user_roles.valid_from IS NULL OR user_roles.valid_from <= ?) AND (user_roles.valid_until IS NULL OR user_roles.valid_until > ?)", now, now)
+}
+
+// ActiveRoleAssignments limits a user_roles query to assignments in effect now.
+// Soft-deleted users keep their assignments until they are purged, so they
+// can be restored with them, but hold nothing in the meantime.
+func ActiveRoleAssignments(db *gorm.DB) *gorm.DB {
+    return currentRoleAssignments(db).
+        Where("NOT EXISTS (SELECT 1 FROM users WHERE users.id = user_roles.user_id AND users.deleted_at IS NOT NULL)")
+}
+
+// usersWithActiveRoles returns a subquery of user ids holding any of the roles,
+// or any role at all when none are given. Soft-deleted users are matched by the
+// roles they will hold again once restored, and left to the users query to
+// include or not.
+func usersWithActiveRoles(db *gorm.DB, roles ...int) *gorm.DB {
+    query := db.Model(&models.UserRole{}).Select("user_roles.user_id").Scopes(currentRoleAssignments)
+    if len(roles) > 0 {
+        query = query.Where("user_roles.role_id IN ?", roles)
+    }
+    return query
+}
+
+func assignRole(tx *gorm.DB, userId string, roleId uint) error {
+    return tx.Clauses(clause.OnConflict{DoNothing: true}).
+        Create(&models.UserRole{UserId: userId, RoleId: roleId}).Error
+}
+
+// SweepExpiredRoleAssignments deletes assignments whose validUntil has passed,
+// clears them as primary role and writes an audit event for each. Expired
+// assignments already grant nothing, see ActiveRoleAssignments, so this only
+// tidies up after them.
+func (t *UserService) SweepExpiredRoleAssignments(now time.Time) (*[]models.UserRole, int, error) {
+    var expired []models.UserRole
+    if err := t.DB.Where("valid_until <= ?", now).Find(&expired).Error; err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    if len(expired) == 0 {
+        return &expired, http.StatusOK, nil
+    }
+
+    tx := t.DB.Begin()
+    for _, assignment := range expired {
+        err := tx.Where("user_id = ? AND role_id = ? AND valid_until <= ?", assignment.UserId, assignment.RoleId, now).
+            Delete(&models.UserRole{}).Error
+        if err != nil {
+            tx.Rollback()
+            return nil, http.StatusInternalServerError, err
+        }
+        // Soft-deleted users too, so restoring them does not bring the role back
+        err = tx.Unscoped().Model(&models.User{}).
+            Where("id = ? AND role = ?", assignment.UserId, assignment.RoleId).
+            Updates(roleUpdate(nil)).Error
+        if err != nil {
+            tx.Rollback()
+            return nil, http.StatusInternalServerError, err
+        }
+    }
+    tx.Commit()
+
+    for _, assignment := range expired {
+        log.WithFields(log.Fields{
+            "ACTOR":  "system",
+            "ACTION": "expire user role",
+            "USER_DETAILS": log.Fields{
+                "id":   assignment.UserId,
+                "role": assignment.RoleId,
+            },
+            "VALID_UNTIL": assignment.ValidUntil,
+        }).Info("USER ROLE EXPIRED")
+    }
+
+    return &expired, http.StatusOK, nil
+}
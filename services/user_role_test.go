@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+	"user-storage/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSweepExpiredRoleAssignments(t *testing.T) {
+    userService := NewUserService(gormDB).WithContext(models.WithActor(context.Background(), "system"))
+    now := time.Now()
+    validUntil := now.Add(-time.Hour)
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `user_roles` WHERE valid_until <= ?")).
+        WithArgs(now).
+        WillReturnRows(sqlmock.NewRows([]string{"user_id", "role_id", "valid_from", "valid_until"}).
+            AddRow("1", 3, nil, validUntil))
+
+    mock.ExpectBegin()
+    mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `user_roles` WHERE user_id = ? AND role_id = ? AND valid_until <= ?")).
+        WithArgs("1", 3, now).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    // Clearing the primary role is stamped with the sweeper as actor, and
+    // moves the user on to a new version
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `role`=?,`version`=version + 1,`updated_at`=?,`updated_by`=? WHERE id = ? AND role = ?")).
+        WithArgs(nil, sqlmock.AnyArg(), "system", "1", 3).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
+
+    expired, statusCode, err := userService.SweepExpiredRoleAssignments(now)
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Len(t, *expired, 1)
+    assert.Equal(t, uint(3), (*expired)[0].RoleId)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddUserRole_InvalidWindow(t *testing.T) {
+    userService := NewUserService(gormDB)
+    validFrom := time.Now().Add(time.Hour)
+    validUntil := time.Now()
+
+    res, statusCode, err := userService.AddUserRole("1", models.UserRole{RoleId: 2, ValidFrom: &validFrom, ValidUntil: &validUntil})
+
+    assert.Error(t, err)
+    assert.Equal(t, http.StatusBadRequest, statusCode)
+    assert.Nil(t, res)
+}
+
+func TestAddUserRole_Conflict(t *testing.T) {
+    userService := NewUserService(gormDB)
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `roles` WHERE id = ?")).
+        WithArgs(4).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(4, "Points Approver"))
+    mock.ExpectBegin()
+    // The conflict check runs against the locked user, inside the transaction
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE id = ? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT 1 FOR UPDATE")).
+        WithArgs("1").
+        WillReturnRows(sqlmock.NewRows(columns).AddRow("1", "John", "Doe", "john@example.com", 3))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `role_id` FROM `user_roles` WHERE user_id = ? AND (user_roles.valid_until IS NULL OR user_roles.valid_until > ?)")).
+        WithArgs("1", sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(3))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `role_conflict_members` WHERE role_id IN (?,?)")).
+        WithArgs(3, 4).
+        WillReturnRows(sqlmock.NewRows([]string{"conflict_id", "role_id"}).AddRow(1, 3).AddRow(1, 4))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `role_conflicts` WHERE id = ?")).
+        WithArgs(1).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Points maker-checker"))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `name` FROM `roles` WHERE id IN (?,?)")).
+        WithArgs(3, 4).
+        WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Points Creator").AddRow("Points Approver"))
+    mock.ExpectRollback()
+
+    res, statusCode, err := userService.AddUserRole("1", models.UserRole{RoleId: 4})
+
+    assert.Error(t, err)
+    assert.Equal(t, http.StatusConflict, statusCode)
+    assert.Equal(t, "Roles Points Creator, Points Approver cannot be held together (Points maker-checker)", err.Error())
+    assert.Nil(t, res)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,11 +1,13 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"regexp"
 	"testing"
+	"time"
 	"user-storage/models"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
@@ -39,6 +41,10 @@ func SetUpDB() (*gorm.DB, sqlmock.Sqlmock){
         log.Fatalf("Error creating GORM DB: %v", err)
     }
 
+    if err := models.RegisterStampCallbacks(gormDB); err != nil {
+        log.Fatalf("Error registering stamp callbacks: %v", err)
+    }
+
     gormDB.AutoMigrate(&models.User{})
 
     // Insert multiple mock user data into the database
@@ -139,12 +145,18 @@ func TestAddUser_Success(t *testing.T) {
 
     firstName, lastName, email, role := "Marilyn", "Monroe", "marilyn@monroe.com", uint(2)
 
-    statement := "INSERT INTO `users` (`id`,`first_name`,`last_name`,`email`,`role`) VALUES (?,?,?,?,?)"
+    statement := "INSERT INTO `users` (`id`,`first_name`,`last_name`,`email`,`version`,`deleted_at`,`created_at`,`updated_at`,`created_by`,`updated_by`,`role`) VALUES (?,?,?,?,?,?,?,?,?,?,?)"
 
     mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE email = ? LIMIT 1")).
+		WithArgs(email).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
     mock.ExpectExec(regexp.QuoteMeta(statement)).
-		WithArgs(sqlmock.AnyArg(), firstName, lastName, email, role).
+		WithArgs(sqlmock.AnyArg(), firstName, lastName, email, 1, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), "", "", role).
 		WillReturnResult(sqlmock.NewResult(1, 0))
+    mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user_roles` (`user_id`,`role_id`) VALUES (?,?)")).
+		WithArgs(sqlmock.AnyArg(), role).
+		WillReturnResult(sqlmock.NewResult(1, 1))
     mock.ExpectCommit()
 
     user := models.User{
@@ -199,11 +211,29 @@ func TestUpdateUserById(t *testing.T) {
         WithArgs("1").
         WillReturnRows(row)
 
-    statement = "UPDATE `users` SET `id`=?,`first_name`=?,`last_name`=?,`email`=?,`role`=? WHERE `id` = ?"
+    // The email changes, so it must not belong to anyone else
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `users` WHERE email = ? AND id <> ? LIMIT 1")).
+        WithArgs(email, "1").
+        WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+    // Only the replaced primary role is held, so no conflict lookup follows
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `role_id` FROM `user_roles` WHERE user_id = ? AND (user_roles.valid_until IS NULL OR user_roles.valid_until > ?)")).
+        WithArgs("1", sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(1))
+
+    statement = "UPDATE `users` SET `id`=?,`first_name`=?,`last_name`=?,`email`=?,`role`=?,`version`=?,`updated_at`=?,`updated_by`=? WHERE version = ? AND `users`.`deleted_at` IS NULL AND `id` = ?"
     id := "1"
 
     mock.ExpectExec(regexp.QuoteMeta(statement)).
-		WithArgs(id, firstName, lastName, email, role, id).
+		WithArgs(id, firstName, lastName, email, role, 1, sqlmock.AnyArg(), "", 0, id).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+    // Primary role changes from 1 to 2
+    mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `user_roles` WHERE user_id = ? AND role_id = ?")).
+		WithArgs(id, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user_roles` (`user_id`,`role_id`) VALUES (?,?)")).
+		WithArgs(id, role).
 		WillReturnResult(sqlmock.NewResult(1, 1))
     mock.ExpectCommit()
 
@@ -228,6 +258,110 @@ func TestUpdateUserById(t *testing.T) {
     assert.Equal(t, role, *res.Role)
 }
 
+func TestUpdateUserById_StaleVersion(t *testing.T) {
+    userService := NewUserService(gormDB)
+
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE id = ?")).
+        WithArgs("1").
+        WillReturnRows(sqlmock.NewRows(append(columns, "version")).AddRow("1", "John1", "Doe", "john1@example.com", nil, 5))
+    mock.ExpectRollback()
+
+    user := models.User{FirstName: "John1", LastName: "Dough", Email: "john1@example.com", Version: 4}
+    res, statusCode, err := userService.UpdateUserById(&user, "1")
+
+    assert.Error(t, err)
+    assert.Equal(t, http.StatusPreconditionFailed, statusCode)
+    assert.Nil(t, res)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreUserById(t *testing.T) {
+    userService := NewUserService(gormDB)
+    deletedAt := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE id = ? ORDER BY `users`.`id` LIMIT 1 FOR UPDATE")).
+        WithArgs("3").
+        WillReturnRows(sqlmock.NewRows(append(columns, "version", "deleted_at")).AddRow("3", "John3", "Doe", "john3@example.com", 4, 2, deletedAt))
+    // The primary role has since been deleted, so it is cleared
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `roles` WHERE id = ?")).
+        WithArgs(4).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+    // The roles the user kept while deleted come back with it
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `role_id` FROM `user_roles` WHERE user_id = ? AND (user_roles.valid_until IS NULL OR user_roles.valid_until > ?)")).
+        WithArgs("3", sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(5))
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `role`=?,`version`=?,`deleted_at`=?,`updated_at`=?,`updated_by`=? WHERE version = ? AND `id` = ?")).
+        WithArgs(nil, 3, nil, sqlmock.AnyArg(), "", 2, "3").
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
+
+    res, statusCode, err := userService.RestoreUserById("3")
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Nil(t, res.Role)
+    assert.Equal(t, 3, res.Version)
+    assert.False(t, res.DeletedAt.Valid)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteUserById_Stamps(t *testing.T) {
+    userService := NewUserService(gormDB).WithContext(models.WithActor(context.Background(), "9"))
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE id = ? AND `users`.`deleted_at` IS NULL")).
+        WithArgs("3").
+        WillReturnRows(sqlmock.NewRows(append(columns, "version", "updated_by")).AddRow("3", "John3", "Doe", "john3@example.com", 4, 2, "1"))
+    mock.ExpectBegin()
+    // The soft delete is stamped with whoever made it
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `deleted_at`=?,`updated_at`=?,`updated_by`=? WHERE id = ? AND `users`.`id` = ? AND `users`.`deleted_at` IS NULL")).
+        WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "9", "3", "3").
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
+
+    res, statusCode, err := userService.DeleteUserById("3")
+
+    assert.NoError(t, err)
+    assert.Equal(t, http.StatusOK, statusCode)
+    assert.Equal(t, "9", res.UpdatedBy)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreUserById_Conflict(t *testing.T) {
+    userService := NewUserService(gormDB)
+    deletedAt := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE id = ? ORDER BY `users`.`id` LIMIT 1 FOR UPDATE")).
+        WithArgs("3").
+        WillReturnRows(sqlmock.NewRows(append(columns, "version", "deleted_at")).AddRow("3", "John3", "Doe", "john3@example.com", 4, 2, deletedAt))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `roles` WHERE id = ?")).
+        WithArgs(4).
+        WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+    // Roles 3 and 4 were made to conflict while the user was deleted
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `role_id` FROM `user_roles` WHERE user_id = ? AND (user_roles.valid_until IS NULL OR user_roles.valid_until > ?)")).
+        WithArgs("3", sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"role_id"}).AddRow(3).AddRow(4))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `role_conflict_members` WHERE role_id IN (?,?)")).
+        WithArgs(3, 4).
+        WillReturnRows(sqlmock.NewRows([]string{"conflict_id", "role_id"}).AddRow(1, 3).AddRow(1, 4))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `role_conflicts` WHERE id = ?")).
+        WithArgs(1).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Points maker-checker"))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT `name` FROM `roles` WHERE id IN (?,?)")).
+        WithArgs(3, 4).
+        WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Points Creator").AddRow("Points Approver"))
+    mock.ExpectRollback()
+
+    res, statusCode, err := userService.RestoreUserById("3")
+
+    assert.Error(t, err)
+    assert.Equal(t, http.StatusConflict, statusCode)
+    assert.Nil(t, res)
+    assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUpdateUserById_BadRequest(t *testing.T) {
     firstName, lastName, role := "Marilyn", "Monroe", uint(2)
 
@@ -78,3 +78,62 @@ resource "aws_api_gateway_method" "this" {
   authorizer_id    = "kjkxid"
   api_key_required = false
 }
+
+# Maintenance jobs run on a schedule, as the function is frozen between
+# requests and cannot keep timers of its own
+resource "aws_cloudwatch_event_rule" "sweep_role_assignments" {
+  name                = "user-storage-sweep-role-assignments"
+  schedule_expression = "rate(5 minutes)"
+}
+
+resource "aws_cloudwatch_event_target" "sweep_role_assignments" {
+  rule  = aws_cloudwatch_event_rule.sweep_role_assignments.name
+  arn   = aws_lambda_function.this.arn
+  input = jsonencode({ job = "sweep-role-assignments" })
+}
+
+resource "aws_lambda_permission" "sweep_role_assignments" {
+  statement_id  = "AllowSweepRoleAssignmentsSchedule"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.this.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.sweep_role_assignments.arn
+}
+
+resource "aws_cloudwatch_event_rule" "purge_deleted_users" {
+  name                = "user-storage-purge-deleted-users"
+  schedule_expression = "rate(1 day)"
+}
+
+resource "aws_cloudwatch_event_target" "purge_deleted_users" {
+  rule  = aws_cloudwatch_event_rule.purge_deleted_users.name
+  arn   = aws_lambda_function.this.arn
+  input = jsonencode({ job = "purge-deleted-users" })
+}
+
+resource "aws_lambda_permission" "purge_deleted_users" {
+  statement_id  = "AllowPurgeDeletedUsersSchedule"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.this.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.purge_deleted_users.arn
+}
+
+resource "aws_cloudwatch_event_rule" "reload_policies" {
+  name                = "user-storage-reload-policies"
+  schedule_expression = "rate(1 minute)"
+}
+
+resource "aws_cloudwatch_event_target" "reload_policies" {
+  rule  = aws_cloudwatch_event_rule.reload_policies.name
+  arn   = aws_lambda_function.this.arn
+  input = jsonencode({ job = "reload-policies" })
+}
+
+resource "aws_lambda_permission" "reload_policies" {
+  statement_id  = "AllowReloadPoliciesSchedule"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.this.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.reload_policies.arn
+}
@@ -2,8 +2,112 @@ create database if not exists usersdb;
 use usersdb;
 create table if not exists users (
   id varchar(36) NOT NULL PRIMARY KEY,
-  email text NOT NULL,
+  email varchar(320) NOT NULL,
   first_name text NOT NULL,
   last_name text NOT NULL,
-  role int
+  role int,
+  version int NOT NULL DEFAULT 1,
+  deleted_at datetime(3) NULL,
+  created_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  updated_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  created_by varchar(64) NOT NULL DEFAULT '',
+  updated_by varchar(64) NOT NULL DEFAULT '',
+  UNIQUE KEY uq_users_email (email),
+  KEY idx_users_deleted_at (deleted_at),
+  FULLTEXT KEY ft_users_search (first_name, last_name, email)
 );
+create table if not exists roles (
+  id int NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  name text NOT NULL,
+  parent_id int,
+  role_rank int NOT NULL DEFAULT 0,
+  version int NOT NULL DEFAULT 1,
+  created_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  updated_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  created_by varchar(64) NOT NULL DEFAULT '',
+  updated_by varchar(64) NOT NULL DEFAULT ''
+);
+create table if not exists access_points (
+  id int NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  name text NOT NULL,
+  method varchar(10) NOT NULL DEFAULT 'ANY',
+  endpoint text NOT NULL,
+  is_admin boolean NOT NULL DEFAULT false,
+  created_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  updated_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  created_by varchar(64) NOT NULL DEFAULT '',
+  updated_by varchar(64) NOT NULL DEFAULT ''
+);
+create table if not exists role_access (
+  role_id int NOT NULL,
+  ap_id int NOT NULL,
+  conditions varchar(1024) NOT NULL DEFAULT '',
+  created_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  updated_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  created_by varchar(64) NOT NULL DEFAULT '',
+  updated_by varchar(64) NOT NULL DEFAULT '',
+  PRIMARY KEY (role_id, ap_id)
+);
+create table if not exists user_roles (
+  user_id varchar(36) NOT NULL,
+  role_id int NOT NULL,
+  valid_from datetime,
+  valid_until datetime,
+  PRIMARY KEY (user_id, role_id),
+  KEY idx_user_roles_valid_until (valid_until)
+);
+create table if not exists role_conflicts (
+  id int NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  name text NOT NULL
+);
+create table if not exists role_conflict_members (
+  conflict_id int NOT NULL,
+  role_id int NOT NULL,
+  PRIMARY KEY (conflict_id, role_id),
+  KEY idx_role_conflict_members_role_id (role_id)
+);
+create table if not exists data_scopes (
+  role_id int NOT NULL,
+  target_role_id int NOT NULL,
+  PRIMARY KEY (role_id, target_role_id)
+);
+create table if not exists field_policies (
+  role_id int NOT NULL,
+  field varchar(32) NOT NULL,
+  PRIMARY KEY (role_id, field)
+);
+create table if not exists pii_policies (
+  role_id int NOT NULL,
+  field varchar(32) NOT NULL,
+  action varchar(8) NOT NULL,
+  PRIMARY KEY (role_id, field)
+);
+-- Product Managers only see users without a role
+insert ignore into data_scopes (role_id, target_role_id) select id, 0 from roles where name = 'Product Manager';
+-- Rank the built-in roles. Holders of only unranked (0) roles cannot manage roles, so rank any others too
+update roles set role_rank = case name when 'Super Admin' then 1000 when 'Admin' then 100 when 'Product Manager' then 50 else role_rank end where role_rank = 0;
+-- Backfill user_roles from the primary role column
+insert ignore into user_roles (user_id, role_id) select id, role from users where role is not null;
+
+-- Upgrading an existing database
+-- alter table access_points add column method varchar(10) NOT NULL DEFAULT 'ANY' after name;
+-- alter table roles add column parent_id int;
+-- alter table user_roles add column valid_from datetime, add column valid_until datetime, add key idx_user_roles_valid_until (valid_until);
+-- alter table role_access add column conditions varchar(1024) NOT NULL DEFAULT '';
+-- alter table roles add column role_rank int NOT NULL DEFAULT 0;
+-- alter table access_points add column is_admin boolean NOT NULL DEFAULT false;
+-- Resolve duplicate emails first: select lower(trim(email)), count(*) from users group by 1 having count(*) > 1;
+-- update users set email = lower(trim(email));
+-- Unlike NormalizeEmail this leaves international domains in Unicode. List them with: select id, email from users where email regexp '[^ -~]'; then save each through PUT /users/accounts/{id}, which converts them, before adding the unique key.
+-- alter table users modify email varchar(320) NOT NULL, add unique key uq_users_email (email);
+-- alter table users add column version int NOT NULL DEFAULT 1;
+-- alter table roles add column version int NOT NULL DEFAULT 1;
+-- alter table users add column deleted_at datetime(3) NULL, add key idx_users_deleted_at (deleted_at);
+-- alter table users add column created_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), add column updated_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), add column created_by varchar(64) NOT NULL DEFAULT '', add column updated_by varchar(64) NOT NULL DEFAULT '';
+-- alter table roles add column created_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), add column updated_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), add column created_by varchar(64) NOT NULL DEFAULT '', add column updated_by varchar(64) NOT NULL DEFAULT '';
+-- alter table access_points add column created_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), add column updated_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), add column created_by varchar(64) NOT NULL DEFAULT '', add column updated_by varchar(64) NOT NULL DEFAULT '';
+-- alter table role_access add column created_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), add column updated_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), add column created_by varchar(64) NOT NULL DEFAULT '', add column updated_by varchar(64) NOT NULL DEFAULT '';
+-- Stamp columns added as NULL before they became NOT NULL: on each of users, roles, access_points and role_access, run
+-- update <table> set created_at = coalesce(created_at, current_timestamp(3)), updated_at = coalesce(updated_at, created_at);
+-- alter table <table> modify created_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), modify updated_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3);
+-- alter table users add fulltext key ft_users_search (first_name, last_name, email);